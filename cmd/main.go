@@ -2,20 +2,28 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"link-base/internal/cache"
 	"link-base/internal/config"
 	"link-base/internal/http"
 	"link-base/internal/repository"
+	"link-base/internal/repository/postgres"
 	"link-base/internal/server"
 	"link-base/internal/service"
+	"link-base/internal/worker"
 	"link-base/pkg/auth"
 	"link-base/pkg/database"
 	"link-base/pkg/hash"
+	"link-base/pkg/migrate"
+	"link-base/pkg/queue"
+	"link-base/pkg/requestid"
+	"link-base/pkg/secret"
+	"link-base/pkg/tracing"
+	"link-base/schema"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -30,36 +38,113 @@ import (
 // @securityDefinitions.apikey UsersAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
 	cfg := config.MustLoad()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
-	fmt.Println("Config: ", cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg.Postgres, os.Args[2:]); err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		return
+	}
+
+	logger := setupLogger(cfg.Log)
 
-	logger := setupLogger()
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
 
 	postgresClient, err := database.NewPostgresClient(cfg.Postgres)
 	if err != nil {
 		log.Fatalf("Failed to initialize Postgres DB: %v", err)
 	}
 
+	if cfg.Postgres.AutoMigrate {
+		applied, err := migrate.Up(context.Background(), postgresClient, schema.Migrations)
+		if err != nil {
+			log.Fatalf("Failed to migrate Postgres DB: %v", err)
+		}
+		logger.Info("database migrated", slog.Int("applied", len(applied)))
+	}
+
 	redisClient, err := database.NewRedisClient(cfg.Redis)
 	if err != nil {
 		log.Fatalf("Failed to initialize Redis DB: %v", err)
 	}
 
-	repos := repository.NewRepository(postgresClient)
-	redis := cache.NewCache(redisClient)
+	postgresReplica, err := database.NewPostgresReplica(cfg.Postgres, postgresClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize Postgres replica: %v", err)
+	}
+
+	repos := repository.NewRepository(postgresClient, postgresReplica, postgres.QueryOptions{
+		Timeout: cfg.Postgres.QueryTimeout,
+		Metrics: cfg.Metrics.Enabled,
+	})
+	redis := cache.NewCache(redisClient, cfg.Cache, logger)
 
-	tokenManager, err := auth.NewManager(cfg.JWT.SigningKey)
+	tokenManager, err := auth.NewManager(cfg.JWT)
 	if err != nil {
 		log.Fatalf("Failed to initialize token manager: %v", err)
 	}
 
-	hasher := hash.NewSHA1Hasher("lolkek")
+	hasher, err := hash.NewHasher(cfg.Hash)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hasher: %v", err)
+	}
+
+	var secretCipher *secret.Cipher
+	if cfg.Auth.TwoFactor.EncryptionKey != "" {
+		if secretCipher, err = secret.NewCipher(cfg.Auth.TwoFactor.EncryptionKey); err != nil {
+			log.Fatalf("Failed to initialize secret cipher: %v", err)
+		}
+	}
+
+	emailQueue := queue.New(cfg.Email.QueueSize, cfg.Email.Workers)
+	webhookQueue := queue.New(cfg.Referral.Webhook.QueueSize, cfg.Referral.Webhook.Workers)
 
-	serv := service.NewService(repos, logger, cfg.JWT, tokenManager, hasher, postgresClient, redis)
+	serv, err := service.NewService(service.Deps{
+		Repos:          repos,
+		Cache:          redis,
+		Logger:         logger,
+		DB:             postgresClient,
+		TokenManager:   tokenManager,
+		Hasher:         hasher,
+		JWTConfig:      cfg.JWT,
+		AuthConfig:     cfg.Auth,
+		SMPTConfig:     cfg.SMPT,
+		EmailConfig:    cfg.Email,
+		ReferralConfig: cfg.Referral,
+		EmailQueue:     emailQueue,
+		WebhookQueue:   webhookQueue,
+		SecretCipher:   secretCipher,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize services: %v", err)
+	}
 
-	handlers := http.NewHandler(serv, tokenManager)
+	handlers := http.NewHandler(http.Deps{
+		Service:        serv,
+		TokenManager:   tokenManager,
+		Limiter:        redis.RateLimiter,
+		Logger:         logger,
+		DB:             postgresClient,
+		Replica:        postgresReplica,
+		Redis:          redisClient,
+		RateLimit:      cfg.RateLimit,
+		CORS:           cfg.CORS,
+		Metrics:        cfg.Metrics,
+		Tracing:        cfg.Tracing,
+		TrustedProxies: cfg.HTTP.TrustedProxies,
+	})
 
 	srv := server.NewServer(cfg.HTTP, handlers.Init())
 	go func() {
@@ -68,36 +153,117 @@ func main() {
 		}
 	}()
 
-	logger.Info("server started", slog.String("address", cfg.HTTP.Port))
+	logger.Info("server started", slog.String("address", cfg.HTTP.Port), slog.Bool("tls", srv.TLS()))
+
+	var metricsSrv *server.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Port != "" {
+		metricsSrv = server.NewServer(config.HTTPConfig{Port: cfg.Metrics.Port}, http.MetricsHandler())
+		go func() {
+			if err := metricsSrv.Run(); err != nil {
+				logger.Info("shutting down metrics server", slog.String("reason", err.Error()))
+			}
+		}()
+	}
+
+	// Background workers share one context and wait group, so shutdown can
+	// signal all of them at once and wait until each has finished.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.NewCleanup(repos, logger, cfg.Cleanup).Run(workerCtx)
+	}()
+
+	if cfg.Health.Monitor {
+		checks := database.Checks(postgresClient, postgresReplica, redisClient)
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.NewHealthMonitor(checks, logger, cfg.Health).Run(workerCtx)
+		}()
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		emailQueue.Run(workerCtx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhookQueue.Run(workerCtx)
+	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 
 	<-quit
 
-	const timeout = 5 * time.Second
+	logger.Info("shutting down", slog.Duration("timeout", cfg.HTTP.ShutdownTimeout))
+
+	// Fail the readiness probe and keep serving for a while, so load balancers
+	// stop sending new requests before the listener is closed.
+	handlers.Drain()
+	time.Sleep(cfg.HTTP.ShutdownDelay)
 
-	ctx, shutdown := context.WithTimeout(context.Background(), timeout)
+	ctx, shutdown := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer shutdown()
 
+	// Stop accepting connections first and let in-flight requests complete,
+	// they may still need the workers and the databases.
 	if err := srv.Stop(ctx); err != nil {
 		logger.Error("failed to stop server", slog.String("reason", err.Error()))
 	}
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		logger.Error("background workers did not stop in time")
+	}
+
+	if metricsSrv != nil {
+		if err := metricsSrv.Stop(ctx); err != nil {
+			logger.Error("failed to stop metrics server", slog.String("reason", err.Error()))
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", slog.String("reason", err.Error()))
+	}
 }
 
-// setupLogger initializes and returns a new logger instance configured
-// with a text handler that outputs to the standard output.
-// The logger is set to debug level and includes the source of the log.
-func setupLogger() *slog.Logger {
-	var logger *slog.Logger
+// setupLogger initializes and returns a new logger instance that outputs to the
+// standard output in the configured format, text or json.
+// The logger uses the configured level, optionally includes the source of the log and
+// adds the request ID of the context to records logged with a context.
+func setupLogger(cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
 
-	// Create a text handler for logging, outputting to standard output
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level:     slog.LevelDebug,
-		AddSource: true,
-	})
+	opts := &slog.HandlerOptions{
+		Level:     level,
+		AddSource: cfg.AddSource,
+	}
 
-	logger = slog.New(handler)
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
 
-	return logger
+	return slog.New(requestid.NewContextHandler(handler))
 }
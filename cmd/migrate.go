@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"link-base/internal/config"
+	"link-base/pkg/database"
+	"link-base/pkg/migrate"
+	"link-base/schema"
+	"log"
+)
+
+// runMigrate runs the migrate subcommand: "migrate up" applies every pending migration
+// and "migrate down" rolls back the latest one.
+//
+// Parameters:
+//   - cfg: The Postgres configuration of the database to be migrated.
+//   - args: The arguments following "migrate".
+//
+// Returns:
+//   - error: An error if the direction is unknown, the database is unreachable or a
+//     migration fails.
+func runMigrate(cfg config.PostgresConfig, args []string) error {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "down") {
+		return errors.New("usage: migrate up|down")
+	}
+
+	db, err := database.NewPostgresClient(cfg)
+	if err != nil {
+		return fmt.Errorf("connect to Postgres: %w", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if args[0] == "down" {
+		m, err := migrate.Down(ctx, db, schema.Migrations)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Rolled back migration %s", m.Name)
+		return nil
+	}
+
+	applied, err := migrate.Up(ctx, db, schema.Migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range applied {
+		log.Printf("Applied migration %s", m.Name)
+	}
+	log.Printf("Database is up to date, %d migrations applied", len(applied))
+
+	return nil
+}
@@ -3,7 +3,10 @@ package cache
 import (
 	"context"
 	InMemoryRedis "link-base/internal/cache/in-memory-redis"
+	"link-base/internal/config"
 	"link-base/internal/domain"
+	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
@@ -12,22 +15,104 @@ import (
 type Referral interface {
 	Create(ctx context.Context, referral domain.Referral) error
 	FindByReferralCode(ctx context.Context, referralCode string) (uuid.UUID, error)
+	FindCodesByUserID(ctx context.Context, userID uuid.UUID) ([]string, error)
+	Delete(ctx context.Context, referralCode string) error
+}
+
+type Blacklist interface {
+	Revoke(ctx context.Context, jti string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type Token interface {
+	Create(ctx context.Context, token, value string, ttl time.Duration) error
+	Consume(ctx context.Context, token string) (string, error)
+}
+
+type LoginAttempts interface {
+	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, cooldown time.Duration) error
+	IsLocked(ctx context.Context, key string) (bool, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type Counter interface {
+	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+}
+
+type Session interface {
+	Create(ctx context.Context, refreshToken string, userID uuid.UUID, ttl time.Duration) error
+	Consume(ctx context.Context, refreshToken string) (uuid.UUID, error)
+	DeleteByUserID(ctx context.Context, userID uuid.UUID, keep string) error
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+type User interface {
+	Get(ctx context.Context, email string) (domain.User, bool, error)
+	Set(ctx context.Context, user domain.User) error
+	Delete(ctx context.Context, email string) error
 }
 
 type Cache struct {
-	Referral Referral
+	Referral      Referral
+	Blacklist     Blacklist
+	PasswordReset Token
+	Verification  Token
+	EmailChange   Token
+	MagicLink     Token
+	TwoFactor     Token
+	LoginAttempts LoginAttempts
+	ReferralIPs   Counter
+	User          User
+	RateLimiter   RateLimiter
+	Session       Session
 }
 
 // NewCache initializes and returns a new Cache instance.
 //
+// Every key is namespaced by its feature under the configured key prefix, so several
+// deployments can share one Redis instance.
+//
+// In fail-open mode the referral code cache, the rate limiter, the sign in lockout and
+// the counters treat Redis errors as misses instead of failing the request.
+//
 // Parameters:
-//   - redisClient: A pointer to a Redis client used to interact with the Redis database.
-//   - logger: A pointer to a slog logger for logging purposes.
+//   - redisClient: A Redis client used to interact with the Redis database.
+//   - cfg: A CacheConfig struct containing the key prefix, the lifetimes of the cached
+//     entries and the fail-open flag.
+//   - logger: A pointer to a slog logger for the errors ignored in fail-open mode.
 //
 // Returns:
 //   - *Cache: A new instance of Cache.
-func NewCache(redisClient *redis.Client) *Cache {
-	return &Cache{
-		Referral: InMemoryRedis.NewReferralRedis(redisClient),
+func NewCache(redisClient redis.UniversalClient, cfg config.CacheConfig, logger *slog.Logger) *Cache {
+	namespace := func(feature string) InMemoryRedis.Namespace {
+		return InMemoryRedis.NewNamespace(cfg.KeyPrefix, feature)
 	}
+
+	c := &Cache{
+		Referral:      InMemoryRedis.NewReferralRedis(redisClient, namespace("referral"), cfg.ReferralCodeTTL),
+		Blacklist:     InMemoryRedis.NewBlacklistRedis(redisClient, namespace("blacklist")),
+		PasswordReset: InMemoryRedis.NewTokenRedis(redisClient, namespace("password-reset")),
+		Verification:  InMemoryRedis.NewTokenRedis(redisClient, namespace("email-verification")),
+		EmailChange:   InMemoryRedis.NewTokenRedis(redisClient, namespace("email-change")),
+		MagicLink:     InMemoryRedis.NewTokenRedis(redisClient, namespace("magic-link")),
+		TwoFactor:     InMemoryRedis.NewTokenRedis(redisClient, namespace("2fa-challenge")),
+		LoginAttempts: InMemoryRedis.NewLoginAttemptsRedis(redisClient, namespace("login")),
+		ReferralIPs:   InMemoryRedis.NewCounterRedis(redisClient, namespace("referral-ip")),
+		User:          InMemoryRedis.NewUserRedis(redisClient, namespace("user"), cfg.UserTTL),
+		RateLimiter:   InMemoryRedis.NewRateLimiterRedis(redisClient, namespace("rate-limit")),
+		Session:       InMemoryRedis.NewSessionRedis(redisClient, namespace("session")),
+	}
+
+	if cfg.FailOpen {
+		c.Referral = failOpenReferral{Referral: c.Referral, logger: logger}
+		c.RateLimiter = failOpenRateLimiter{RateLimiter: c.RateLimiter, logger: logger}
+		c.LoginAttempts = failOpenLoginAttempts{LoginAttempts: c.LoginAttempts, logger: logger}
+		c.ReferralIPs = failOpenCounter{Counter: c.ReferralIPs, logger: logger}
+	}
+
+	return c
 }
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/domain"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The fail-open decorators wrap the caches whose errors don't affect correctness. A Redis
+// error is logged and treated as a miss, an allowed request or an unlocked key, so the
+// service keeps serving while Redis is unavailable. The revocation blacklist, sessions
+// and single-use tokens are never wrapped.
+
+type failOpenReferral struct {
+	Referral
+	logger *slog.Logger
+}
+
+// FindByReferralCode treats a failed lookup as a miss, so the code is looked up in the
+// database instead.
+func (r failOpenReferral) FindByReferralCode(ctx context.Context, referralCode string) (uuid.UUID, error) {
+	id, err := r.Referral.FindByReferralCode(ctx, referralCode)
+	if err != nil && !errors.Is(err, domain.ErrReferralCodeNotFound) {
+		logFailOpen(ctx, r.logger, "referral", err)
+		return uuid.Nil, domain.ErrReferralCodeNotFound
+	}
+
+	return id, err
+}
+
+// FindCodesByUserID treats a failed lookup as a miss.
+func (r failOpenReferral) FindCodesByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes, err := r.Referral.FindCodesByUserID(ctx, userID)
+	if err != nil {
+		logFailOpen(ctx, r.logger, "referral", err)
+		return nil, nil
+	}
+
+	return codes, nil
+}
+
+type failOpenRateLimiter struct {
+	RateLimiter
+	logger *slog.Logger
+}
+
+// Allow allows the request if the limiter can't be queried.
+func (r failOpenRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	allowed, err := r.RateLimiter.Allow(ctx, key, limit, window)
+	if err != nil {
+		logFailOpen(ctx, r.logger, "rate-limit", err)
+		return true, nil
+	}
+
+	return allowed, nil
+}
+
+type failOpenLoginAttempts struct {
+	LoginAttempts
+	logger *slog.Logger
+}
+
+// IsLocked treats the key as unlocked if the lock can't be queried.
+func (r failOpenLoginAttempts) IsLocked(ctx context.Context, key string) (bool, error) {
+	locked, err := r.LoginAttempts.IsLocked(ctx, key)
+	if err != nil {
+		logFailOpen(ctx, r.logger, "login-attempts", err)
+		return false, nil
+	}
+
+	return locked, nil
+}
+
+type failOpenCounter struct {
+	Counter
+	logger *slog.Logger
+}
+
+// Increment reports no events if the counter can't be updated.
+func (r failOpenCounter) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	n, err := r.Counter.Increment(ctx, key, window)
+	if err != nil {
+		logFailOpen(ctx, r.logger, "counter", err)
+		return 0, nil
+	}
+
+	return n, nil
+}
+
+// logFailOpen logs a Redis error that is ignored in fail-open mode.
+func logFailOpen(ctx context.Context, logger *slog.Logger, cache string, err error) {
+	logger.WarnContext(ctx, "cache unavailable, failing open",
+		slog.String("cache", cache),
+		slog.String("reason", err.Error()),
+	)
+}
@@ -0,0 +1,61 @@
+package in_memory_redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type BlacklistRedis struct {
+	redisClient redis.UniversalClient
+	keys        Namespace
+}
+
+// NewBlacklistRedis creates a new instance of BlacklistRedis storing the token IDs in the namespace.
+func NewBlacklistRedis(client redis.UniversalClient, keys Namespace) *BlacklistRedis {
+	return &BlacklistRedis{
+		redisClient: client,
+		keys:        keys,
+	}
+}
+
+// Revoke puts the token ID into the blacklist for the given TTL.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - jti: The ID of the access token to be revoked.
+//   - ttl: The remaining lifetime of the token, after which the entry expires.
+//
+// Returns:
+//   - error: An error if the token ID can't be stored in Redis.
+func (r *BlacklistRedis) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := r.redisClient.Set(ctx, r.keys.Key(jti), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("error revoking token in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the token ID is present in the blacklist.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - jti: The ID of the access token to check.
+//
+// Returns:
+//   - bool: True if the token has been revoked.
+//   - error: An error if Redis can't be queried.
+func (r *BlacklistRedis) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.redisClient.Exists(ctx, r.keys.Key(jti)).Result()
+	if err != nil {
+		return false, fmt.Errorf("error checking revoked token in Redis: %w", err)
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,46 @@
+package in_memory_redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CounterRedis counts events under a namespaced key within a fixed window.
+type CounterRedis struct {
+	redisClient redis.UniversalClient
+	keys        Namespace
+}
+
+// NewCounterRedis creates a new instance of CounterRedis keeping its counters in the namespace.
+func NewCounterRedis(client redis.UniversalClient, keys Namespace) *CounterRedis {
+	return &CounterRedis{
+		redisClient: client,
+		keys:        keys,
+	}
+}
+
+// Increment counts an event for the key within the given window.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key the events are counted for.
+//   - window: The period the events are counted in, starting with the first event.
+//
+// Returns:
+//   - int64: The number of events within the current window.
+//   - error: An error if the counter can't be updated in Redis.
+func (r *CounterRedis) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := r.redisClient.TxPipeline()
+	counter := r.keys.Key(key)
+	incr := pipe.Incr(ctx, counter)
+	pipe.ExpireNX(ctx, counter, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("error incrementing counter in Redis: %w", err)
+	}
+
+	return incr.Val(), nil
+}
@@ -0,0 +1,38 @@
+package in_memory_redis
+
+import "strings"
+
+// Namespace builds the Redis keys of one cache feature, e.g. "link-base:referral:code:<code>".
+//
+// Every key starts with the configured key prefix followed by the feature and its
+// sub-namespaces, so features never collide with each other or with other applications
+// sharing the Redis instance.
+type Namespace struct {
+	base string
+}
+
+// NewNamespace creates the namespace of the feature under the key prefix.
+//
+// Parameters:
+//   - prefix: The key prefix shared by all features, or empty for none.
+//   - feature: The name of the feature, e.g. "referral".
+//
+// Returns:
+//   - Namespace: The namespace of the feature.
+func NewNamespace(prefix, feature string) Namespace {
+	prefix = strings.TrimSuffix(prefix, ":")
+	if prefix == "" {
+		return Namespace{base: feature}
+	}
+	return Namespace{base: prefix + ":" + feature}
+}
+
+// Sub returns the sub-namespace with the given name, e.g. "referral:user".
+func (n Namespace) Sub(name string) Namespace {
+	return Namespace{base: n.base + ":" + name}
+}
+
+// Key joins the parts into a key of the namespace.
+func (n Namespace) Key(parts ...string) string {
+	return n.base + ":" + strings.Join(parts, ":")
+}
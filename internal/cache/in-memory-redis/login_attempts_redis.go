@@ -0,0 +1,103 @@
+package in_memory_redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginAttemptsRedis struct {
+	redisClient redis.UniversalClient
+	attempts    Namespace
+	locks       Namespace
+}
+
+// NewLoginAttemptsRedis creates a new instance of LoginAttemptsRedis keeping the failures and
+// locks in sub-namespaces of the namespace.
+func NewLoginAttemptsRedis(client redis.UniversalClient, keys Namespace) *LoginAttemptsRedis {
+	return &LoginAttemptsRedis{
+		redisClient: client,
+		attempts:    keys.Sub("attempts"),
+		locks:       keys.Sub("lock"),
+	}
+}
+
+// Increment counts a failed sign in for the key within the given window.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key the failures are counted for, e.g. an email.
+//   - window: The period the failures are counted in, starting with the first failure.
+//
+// Returns:
+//   - int64: The number of failures within the current window.
+//   - error: An error if the counter can't be updated in Redis.
+func (r *LoginAttemptsRedis) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := r.redisClient.TxPipeline()
+	counter := r.attempts.Key(key)
+	incr := pipe.Incr(ctx, counter)
+	pipe.ExpireNX(ctx, counter, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("error counting failed sign in in Redis: %w", err)
+	}
+
+	return incr.Val(), nil
+}
+
+// Lock blocks sign ins for the key for the given cooldown and resets its failure counter.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key to be locked.
+//   - cooldown: The period the key stays locked.
+//
+// Returns:
+//   - error: An error if the lock can't be stored in Redis.
+func (r *LoginAttemptsRedis) Lock(ctx context.Context, key string, cooldown time.Duration) error {
+	pipe := r.redisClient.Pipeline()
+	pipe.Set(ctx, r.locks.Key(key), 1, cooldown)
+	pipe.Del(ctx, r.attempts.Key(key))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error locking sign in in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// IsLocked reports whether sign ins for the key are currently locked.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key to check.
+//
+// Returns:
+//   - bool: True if the key is locked.
+//   - error: An error if Redis can't be queried.
+func (r *LoginAttemptsRedis) IsLocked(ctx context.Context, key string) (bool, error) {
+	n, err := r.redisClient.Exists(ctx, r.locks.Key(key)).Result()
+	if err != nil {
+		return false, fmt.Errorf("error checking sign in lock in Redis: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// Reset clears the failure counter of the key.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key to be reset.
+//
+// Returns:
+//   - error: An error if the counter can't be deleted from Redis.
+func (r *LoginAttemptsRedis) Reset(ctx context.Context, key string) error {
+	if err := r.redisClient.Del(ctx, r.attempts.Key(key)).Err(); err != nil {
+		return fmt.Errorf("error resetting failed sign ins in Redis: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package in_memory_redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiterRedis is a fixed-window rate limiter shared by all API instances.
+type RateLimiterRedis struct {
+	redisClient redis.UniversalClient
+	keys        Namespace
+}
+
+// NewRateLimiterRedis creates a new instance of RateLimiterRedis keeping its counters in the namespace.
+func NewRateLimiterRedis(client redis.UniversalClient, keys Namespace) *RateLimiterRedis {
+	return &RateLimiterRedis{
+		redisClient: client,
+		keys:        keys,
+	}
+}
+
+// Allow counts a request for the key and reports whether it is within the limit of the current window.
+//
+// The counter and its expiry are set in a single MULTI/EXEC, so the first request of a
+// window never leaves a counter without a TTL behind.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The key the requests are counted for, e.g. a user ID or client IP.
+//   - limit: The maximum number of requests allowed per window.
+//   - window: The length of the window, starting with its first request.
+//
+// Returns:
+//   - bool: True if the request is within the limit.
+//   - error: An error if the counter can't be updated in Redis.
+func (r *RateLimiterRedis) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	pipe := r.redisClient.TxPipeline()
+	counter := r.keys.Key(key)
+	incr := pipe.Incr(ctx, counter)
+	pipe.ExpireNX(ctx, counter, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("error counting request in Redis: %w", err)
+	}
+
+	return incr.Val() <= int64(limit), nil
+}
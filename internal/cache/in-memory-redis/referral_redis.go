@@ -2,43 +2,101 @@ package in_memory_redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"link-base/internal/domain"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type ReferralRedis struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
+	codes       Namespace
+	users       Namespace
+	maxTTL      time.Duration
 }
 
-// NewReferralRedis creates a new instance of ReferralRedis.
-func NewReferralRedis(client *redis.Client) *ReferralRedis {
+// NewReferralRedis creates a new instance of ReferralRedis caching codes in the namespace for
+// at most maxTTL, or until they expire if maxTTL is 0. The codes and the index of each user's
+// codes are kept in separate sub-namespaces.
+func NewReferralRedis(client redis.UniversalClient, keys Namespace, maxTTL time.Duration) *ReferralRedis {
 	return &ReferralRedis{
 		redisClient: client,
+		codes:       keys.Sub("code"),
+		users:       keys.Sub("user"),
+		maxTTL:      maxTTL,
 	}
 }
 
 // Create sets a referral code in Redis with a TTL.
 //
+// The code is cached for its TTL, capped by the configured maximum. It is only set if it
+// doesn't exist yet, so an active code is never overwritten. The code is also added to
+// the reverse index of the user's codes, scored by the expiry of its cache entry.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - referral: A domain.Referral struct containing the referral code and user ID.
 //
 // Returns:
-//   - error: An error if the referral code can't be created in Redis.
+//   - error: domain.ErrReferralCodeTaken if the code is already in use, or an error if the
+//     referral code can't be created in Redis.
 func (r *ReferralRedis) Create(ctx context.Context, referral domain.Referral) error {
-	fmt.Println("Creating referral code")
+	ttl := referral.TTL
+	if r.maxTTL > 0 && ttl > r.maxTTL {
+		ttl = r.maxTTL
+	}
 
-	_, err := r.redisClient.Set(ctx, referral.ReferralCode, referral.UserId.String(), referral.TTL).Result() // Приводим UserId к строке
+	ok, err := r.redisClient.SetNX(ctx, r.codes.Key(referral.ReferralCode), referral.UserId.String(), ttl).Result()
 	if err != nil {
 		return fmt.Errorf("error setting referral code in Redis: %w", err)
 	}
 
+	if !ok {
+		return domain.ErrReferralCodeTaken
+	}
+
+	key := r.users.Key(referral.UserId.String())
+	expiresAt := time.Now().Add(ttl)
+
+	pipe := r.redisClient.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt.Unix()), Member: referral.ReferralCode})
+	pipe.ExpireGT(ctx, key, ttl)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error indexing referral code in Redis: %w", err)
+	}
+
 	return nil
 }
 
+// FindCodesByUserID retrieves the active referral codes of the user from the reverse index.
+//
+// Expired codes are pruned from the index before it is read.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose codes are to be retrieved.
+//
+// Returns:
+//   - []string: The active referral codes of the user.
+//   - error: An error if Redis can't be queried.
+func (r *ReferralRedis) FindCodesByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	key := r.users.Key(userID.String())
+
+	pipe := r.redisClient.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	codes := pipe.ZRange(ctx, key, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error getting referral codes from Redis: %w", err)
+	}
+
+	return codes.Val(), nil
+}
+
 // FindByReferralCode retrieves the creator of the referral code from Redis.
 //
 // Parameters:
@@ -47,12 +105,13 @@ func (r *ReferralRedis) Create(ctx context.Context, referral domain.Referral) er
 //
 // Returns:
 //   - uuid.UUID: The user ID of the referral code creator if found.
-//   - error: An error if the referral code can't be found in Redis.
+//   - error: domain.ErrReferralCodeNotFound if the code doesn't exist or has expired, or an
+//     error if Redis can't be queried.
 func (r *ReferralRedis) FindByReferralCode(ctx context.Context, referralCode string) (uuid.UUID, error) {
-	creatorIDStr, err := r.redisClient.Get(ctx, referralCode).Result()
+	creatorIDStr, err := r.redisClient.Get(ctx, r.codes.Key(referralCode)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return uuid.Nil, fmt.Errorf("referral code not found: %s", referralCode)
+			return uuid.Nil, fmt.Errorf("%w: %s", domain.ErrReferralCodeNotFound, referralCode)
 		}
 		return uuid.Nil, fmt.Errorf("error getting referral code from Redis: %w", err)
 	}
@@ -64,3 +123,34 @@ func (r *ReferralRedis) FindByReferralCode(ctx context.Context, referralCode str
 
 	return id, nil
 }
+
+// Delete removes the referral code from Redis together with its reverse index entry.
+//
+// Deleting a code that doesn't exist or has already expired is a no-op.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - referralCode: The referral code to be deleted.
+//
+// Returns:
+//   - error: An error if the referral code can't be deleted from Redis.
+func (r *ReferralRedis) Delete(ctx context.Context, referralCode string) error {
+	owner, err := r.redisClient.Get(ctx, r.codes.Key(referralCode)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting referral code from Redis: %w", err)
+	}
+
+	pipe := r.redisClient.Pipeline()
+	pipe.Del(ctx, r.codes.Key(referralCode))
+	if userID, err := uuid.Parse(owner); err == nil {
+		pipe.ZRem(ctx, r.users.Key(userID.String()), referralCode)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error deleting referral code from Redis: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package in_memory_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionRedis stores refresh token sessions keyed by token, with an index of the tokens of each user.
+type SessionRedis struct {
+	redisClient redis.UniversalClient
+	tokens      Namespace
+	users       Namespace
+}
+
+// NewSessionRedis creates a new instance of SessionRedis keeping the sessions and the index of
+// each user in sub-namespaces of the namespace.
+func NewSessionRedis(client redis.UniversalClient, keys Namespace) *SessionRedis {
+	return &SessionRedis{
+		redisClient: client,
+		tokens:      keys.Sub("token"),
+		users:       keys.Sub("user"),
+	}
+}
+
+// Create stores the refresh token of the user for the given TTL.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - refreshToken: The refresh token of the session.
+//   - userID: The UUID of the user the session belongs to.
+//   - ttl: The lifetime of the session.
+//
+// Returns:
+//   - error: An error if the session can't be stored in Redis.
+func (r *SessionRedis) Create(ctx context.Context, refreshToken string, userID uuid.UUID, ttl time.Duration) error {
+	index := r.users.Key(userID.String())
+
+	pipe := r.redisClient.Pipeline()
+	pipe.Set(ctx, r.tokens.Key(refreshToken), userID.String(), ttl)
+	pipe.SAdd(ctx, index, refreshToken)
+	pipe.Expire(ctx, index, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error setting session in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// Consume atomically reads and deletes the session, so a refresh token can only be used once.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - refreshToken: The refresh token of the session.
+//
+// Returns:
+//   - uuid.UUID: The UUID of the user the session belongs to.
+//   - error: domain.ErrSessionNotFound if the session doesn't exist or has expired,
+//     or an error if Redis can't be queried.
+func (r *SessionRedis) Consume(ctx context.Context, refreshToken string) (uuid.UUID, error) {
+	value, err := r.redisClient.GetDel(ctx, r.tokens.Key(refreshToken)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return uuid.Nil, domain.ErrSessionNotFound
+		}
+		return uuid.Nil, fmt.Errorf("error getting session from Redis: %w", err)
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("error parsing user ID from session: %w", err)
+	}
+
+	if err := r.redisClient.SRem(ctx, r.users.Key(value), refreshToken).Err(); err != nil {
+		return uuid.Nil, fmt.Errorf("error deleting session from Redis: %w", err)
+	}
+
+	return userID, nil
+}
+
+// DeleteByUserID deletes all sessions of the user except the one with the given refresh token.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose sessions are to be deleted.
+//   - keep: The refresh token of the session to be kept, or an empty string to delete all sessions.
+//
+// Returns:
+//   - error: An error if the sessions can't be deleted from Redis.
+func (r *SessionRedis) DeleteByUserID(ctx context.Context, userID uuid.UUID, keep string) error {
+	index := r.users.Key(userID.String())
+
+	tokens, err := r.redisClient.SMembers(ctx, index).Result()
+	if err != nil {
+		return fmt.Errorf("error getting sessions from Redis: %w", err)
+	}
+
+	pipe := r.redisClient.Pipeline()
+	for _, token := range tokens {
+		if token == keep {
+			continue
+		}
+
+		pipe.Del(ctx, r.tokens.Key(token))
+		pipe.SRem(ctx, index, token)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error deleting sessions from Redis: %w", err)
+	}
+
+	return nil
+}
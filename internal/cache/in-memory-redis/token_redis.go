@@ -0,0 +1,65 @@
+package in_memory_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenRedis stores single-use tokens under a namespaced key.
+type TokenRedis struct {
+	redisClient redis.UniversalClient
+	keys        Namespace
+}
+
+// NewTokenRedis creates a new instance of TokenRedis storing the tokens in the namespace.
+func NewTokenRedis(client redis.UniversalClient, keys Namespace) *TokenRedis {
+	return &TokenRedis{
+		redisClient: client,
+		keys:        keys,
+	}
+}
+
+// Create stores the token with its value for the given TTL.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The single-use token.
+//   - value: The value associated with the token, e.g. a user ID.
+//   - ttl: The lifetime of the token.
+//
+// Returns:
+//   - error: An error if the token can't be stored in Redis.
+func (r *TokenRedis) Create(ctx context.Context, token, value string, ttl time.Duration) error {
+	if err := r.redisClient.Set(ctx, r.keys.Key(token), value, ttl).Err(); err != nil {
+		return fmt.Errorf("error setting token in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// Consume atomically reads and deletes the token, so it can only be used once.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The single-use token.
+//
+// Returns:
+//   - string: The value associated with the token.
+//   - error: domain.ErrTokenNotFound if the token doesn't exist or has expired,
+//     or an error if Redis can't be queried.
+func (r *TokenRedis) Consume(ctx context.Context, token string) (string, error) {
+	value, err := r.redisClient.GetDel(ctx, r.keys.Key(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", domain.ErrTokenNotFound
+		}
+		return "", fmt.Errorf("error getting token from Redis: %w", err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,96 @@
+package in_memory_redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UserRedis caches users keyed by their email.
+//
+// The cached user includes the password hash and salt, since sign ins are verified
+// against the cached user. Entries are therefore short-lived and dropped whenever the
+// user changes, and the cache must live in a Redis instance as trusted as Postgres.
+type UserRedis struct {
+	redisClient redis.UniversalClient
+	emails      Namespace
+	ttl         time.Duration
+}
+
+// NewUserRedis creates a new instance of UserRedis caching users in the namespace for the given TTL.
+func NewUserRedis(client redis.UniversalClient, keys Namespace, ttl time.Duration) *UserRedis {
+	return &UserRedis{
+		redisClient: client,
+		emails:      keys.Sub("email"),
+		ttl:         ttl,
+	}
+}
+
+// Get retrieves the cached user with the given email.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The normalized email of the user.
+//
+// Returns:
+//   - domain.User: The cached user.
+//   - bool: True if the user was cached.
+//   - error: An error if Redis can't be queried or the cached value is malformed.
+func (r *UserRedis) Get(ctx context.Context, email string) (domain.User, bool, error) {
+	value, err := r.redisClient.Get(ctx, r.emails.Key(email)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return domain.User{}, false, nil
+		}
+		return domain.User{}, false, fmt.Errorf("error getting user from Redis: %w", err)
+	}
+
+	var user domain.User
+	if err := json.Unmarshal(value, &user); err != nil {
+		return domain.User{}, false, fmt.Errorf("error decoding cached user: %w", err)
+	}
+
+	return user, true, nil
+}
+
+// Set caches the user under their email for the configured TTL.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - user: The user to be cached.
+//
+// Returns:
+//   - error: An error if the user can't be stored in Redis.
+func (r *UserRedis) Set(ctx context.Context, user domain.User) error {
+	value, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("error encoding user: %w", err)
+	}
+
+	if err := r.redisClient.Set(ctx, r.emails.Key(user.Email), value, r.ttl).Err(); err != nil {
+		return fmt.Errorf("error setting user in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the cached user with the given email.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The normalized email of the user.
+//
+// Returns:
+//   - error: An error if the user can't be deleted from Redis.
+func (r *UserRedis) Delete(ctx context.Context, email string) error {
+	if err := r.redisClient.Del(ctx, r.emails.Key(email)).Err(); err != nil {
+		return fmt.Errorf("error deleting user from Redis: %w", err)
+	}
+
+	return nil
+}
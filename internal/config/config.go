@@ -8,78 +8,314 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+// Values are resolved in the following order, each one overriding the previous:
+// env-default tags, the yaml file at CONFIG_PATH, and the environment variables
+// named by the env tags. Fields without an env tag can only be set from the file.
 type (
 	Config struct {
-		HTTP     HTTPConfig
-		Postgres PostgresConfig
-		Redis    RedisConfig
-		JWT      JWTConfig
-		SMPT     SMPTConfig
+		HTTP      HTTPConfig
+		Postgres  PostgresConfig
+		Redis     RedisConfig
+		JWT       JWTConfig
+		Auth      AuthConfig
+		SMPT      SMPTConfig
+		Email     EmailConfig
+		Hash      HashConfig
+		Referral  ReferralConfig
+		Cleanup   CleanupConfig
+		Cache     CacheConfig
+		Health    HealthConfig
+		RateLimit RateLimitConfig
+		CORS      CORSConfig
+		Metrics   MetricsConfig
+		Tracing   TracingConfig
+		Log       LogConfig
 	}
 
 	HTTPConfig struct {
-		Port           string        `yaml:"port"`
-		MaxHeaderBytes int           `yaml:"maxHeaderBytes"`
-		ReadTimeout    time.Duration `yaml:"readTimeout"`
-		WriteTimeout   time.Duration `yaml:"writeTimeout"`
+		Port              string        `yaml:"port" env:"HTTP_PORT" env-default:"8080"`
+		MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+		ReadTimeout       time.Duration `yaml:"readTimeout"`
+		ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+		WriteTimeout      time.Duration `yaml:"writeTimeout"`
+		IdleTimeout       time.Duration `yaml:"idleTimeout"`
+		ShutdownDelay     time.Duration `yaml:"shutdownDelay" env:"HTTP_SHUTDOWN_DELAY"`
+		ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"15s"`
+		TLS               TLSConfig     `yaml:"tls"`
+		// TrustedProxies lists the proxy IPs or CIDRs whose forwarding headers are trusted
+		// to resolve the client IP. Without any, the peer address is the client IP.
+		TrustedProxies []string `yaml:"trustedProxies" env:"HTTP_TRUSTED_PROXIES" env-separator:","`
+	}
+
+	TLSConfig struct {
+		CertFile string `yaml:"certFile" env:"HTTP_TLS_CERT_FILE"`
+		KeyFile  string `yaml:"keyFile" env:"HTTP_TLS_KEY_FILE"`
 	}
 
 	PostgresConfig struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		User     string `env:"POSTGRES_USER" env-default:"postgres"`
-		Password string `env:"POSTGRES_PASSWORD" env-required:"true"`
-		Database string `yaml:"database"`
-		SSLMode  string `yaml:"sslMode"`
+		Host     string      `yaml:"host" env:"POSTGRES_HOST" env-default:"localhost"`
+		Port     string      `yaml:"port" env:"POSTGRES_PORT" env-default:"5432"`
+		User     string      `env:"POSTGRES_USER" env-default:"postgres"`
+		Password string      `env:"POSTGRES_PASSWORD" env-required:"true"`
+		Database string      `yaml:"database" env:"POSTGRES_DATABASE" env-default:"postgres"`
+		SSLMode  string      `yaml:"sslMode" env:"POSTGRES_SSLMODE" env-default:"disable"`
+		Retry    RetryConfig `yaml:"retry"`
+		// ReplicaDSN is the connection string of a read replica. Without it, reads go to
+		// the primary.
+		ReplicaDSN string `env:"POSTGRES_REPLICA_DSN"`
+		// QueryTimeout bounds every repository query; 0 disables it.
+		QueryTimeout time.Duration `yaml:"queryTimeout" env:"POSTGRES_QUERY_TIMEOUT" env-default:"5s"`
+		// AutoMigrate applies pending migrations at startup.
+		AutoMigrate bool `yaml:"autoMigrate" env:"POSTGRES_AUTO_MIGRATE"`
 	}
 
 	RedisConfig struct {
-		Address        string        `yaml:"addr"`
+		Mode           string        `yaml:"mode" env:"REDIS_MODE" env-default:"standalone"`
+		Address        string        `yaml:"addr" env:"REDIS_ADDR" env-default:"localhost:6379"`
+		Username       string        `env:"REDIS_USER"`
+		Password       string        `env:"REDIS_PASSWORD"`
+		MasterName     string        `yaml:"masterName"`
+		SentinelAddrs  []string      `yaml:"sentinelAddrs"`
+		ClusterAddrs   []string      `yaml:"clusterAddrs"`
 		DatabaseNumber int           `yaml:"numberDB"`
 		DialTimeout    time.Duration `yaml:"dialTimeout"`
 		ReadTimeout    time.Duration `yaml:"readTimeout"`
 		WriteTimeout   time.Duration `yaml:"writeTimeout"`
 		PoolSize       int           `yaml:"poolSize"`
 		MinIdleConns   int           `yaml:"minIdleConns"`
+		Retry          RetryConfig   `yaml:"retry"`
+	}
+
+	RetryConfig struct {
+		MaxAttempts int           `yaml:"maxAttempts" env-default:"1"`
+		BaseDelay   time.Duration `yaml:"baseDelay" env-default:"500ms"`
 	}
 
 	JWTConfig struct {
-		AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
-		RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
-		SigningKey      string        `env:"SIGNING_KEY"`
+		AccessTokenTTL  time.Duration     `yaml:"accessTokenTTL" env:"JWT_ACCESS_TOKEN_TTL" env-default:"15m"`
+		RefreshTokenTTL time.Duration     `yaml:"refreshTokenTTL" env:"JWT_REFRESH_TOKEN_TTL" env-default:"720h"`
+		Algorithm       string            `yaml:"algorithm" env-default:"HS256"`
+		SigningKey      string            `env:"SIGNING_KEY"`
+		SigningKeys     map[string]string `yaml:"signingKeys" env:"JWT_SIGNING_KEYS"`
+		ActiveKeyID     string            `yaml:"activeKeyId" env:"JWT_ACTIVE_KEY_ID"`
+		PrivateKeyPath  string            `yaml:"privateKeyPath" env:"JWT_PRIVATE_KEY_PATH"`
+		PublicKeyPath   string            `yaml:"publicKeyPath" env:"JWT_PUBLIC_KEY_PATH"`
+		Issuer          string            `yaml:"issuer" env:"JWT_ISSUER"`
+		Audience        string            `yaml:"audience" env:"JWT_AUDIENCE"`
+	}
+
+	AuthConfig struct {
+		PasswordResetTTL time.Duration        `yaml:"passwordResetTTL" env-default:"1h"`
+		VerificationTTL  time.Duration        `yaml:"verificationTTL" env-default:"24h"`
+		VerificationURL  string               `yaml:"verificationURL"`
+		EmailChangeTTL   time.Duration        `yaml:"emailChangeTTL" env-default:"24h"`
+		EmailConfirmURL  string               `yaml:"emailConfirmURL"`
+		MagicLinkTTL     time.Duration        `yaml:"magicLinkTTL" env-default:"15m"`
+		MagicLinkURL     string               `yaml:"magicLinkURL"`
+		TwoFactor        TwoFactorConfig      `yaml:"twoFactor"`
+		Lockout          LockoutConfig        `yaml:"lockout"`
+		PasswordPolicy   PasswordPolicyConfig `yaml:"passwordPolicy"`
+		SessionStore     string               `yaml:"sessionStore" env-default:"postgres"`
+		MaxSessions      int                  `yaml:"maxSessions" env-default:"10"`
+	}
+
+	// TwoFactorConfig configures TOTP two-factor authentication. EncryptionKey is the
+	// hex-encoded 32-byte AES key TOTP secrets are encrypted with; 2FA enrollment is
+	// unavailable while it is empty.
+	TwoFactorConfig struct {
+		Issuer        string        `yaml:"issuer" env-default:"LinkBase"`
+		EncryptionKey string        `yaml:"encryptionKey" env:"TOTP_ENCRYPTION_KEY"`
+		ChallengeTTL  time.Duration `yaml:"challengeTTL" env-default:"5m"`
+		Skew          int           `yaml:"skew" env-default:"1"`
+	}
+
+	PasswordPolicyConfig struct {
+		MinLength     int  `yaml:"minLength" env-default:"8"`
+		RequireUpper  bool `yaml:"requireUpper"`
+		RequireLower  bool `yaml:"requireLower"`
+		RequireDigit  bool `yaml:"requireDigit"`
+		RequireSymbol bool `yaml:"requireSymbol"`
+	}
+
+	LockoutConfig struct {
+		MaxAttempts int64         `yaml:"maxAttempts" env-default:"5"`
+		Window      time.Duration `yaml:"window" env-default:"15m"`
+		Cooldown    time.Duration `yaml:"cooldown" env-default:"15m"`
+		ByIP        bool          `yaml:"byIP"`
+	}
+
+	ReferralConfig struct {
+		MaxActiveCodes int           `yaml:"maxActiveCodes" env-default:"1"`
+		MinTTL         time.Duration `yaml:"minTTL" env-default:"1m"`
+		MaxTTL         time.Duration `yaml:"maxTTL" env-default:"720h"`
+		EmailTemplate  string        `yaml:"emailTemplate" env-default:"templates/referral_email.html"`
+		// StrictSignUp rejects sign ups with an unknown or expired referral code. Otherwise
+		// the user is signed up without a referral.
+		StrictSignUp bool `yaml:"strictSignUp" env-default:"true"`
+		// RewardPoints is credited to the referrer for every referred sign up.
+		RewardPoints int `yaml:"rewardPoints" env-default:"10"`
+		// LinkURL is the public sign up page referral links point to; the code is
+		// appended as the ref query parameter.
+		LinkURL string              `yaml:"linkURL" env-default:"http://localhost:8080/signup"`
+		Fraud   ReferralFraudConfig `yaml:"fraud"`
+		Webhook WebhookConfig       `yaml:"webhook"`
+	}
+
+	// WebhookConfig configures the notification of partners about referred sign ups.
+	// Webhooks are disabled without a URL.
+	WebhookConfig struct {
+		URL string `yaml:"url" env:"REFERRAL_WEBHOOK_URL"`
+		// Secret signs the payloads with HMAC-SHA256.
+		Secret    string        `yaml:"secret" env:"REFERRAL_WEBHOOK_SECRET"`
+		Timeout   time.Duration `yaml:"timeout" env-default:"5s"`
+		QueueSize int           `yaml:"queueSize" env-default:"100"`
+		Workers   int           `yaml:"workers" env-default:"2"`
+		Retry     RetryConfig   `yaml:"retry"`
+	}
+
+	// ReferralFraudConfig limits how many referred sign ups of one referrer may share an IP.
+	ReferralFraudConfig struct {
+		Enabled bool `yaml:"enabled" env:"REFERRAL_FRAUD_ENABLED"`
+		// MaxSignUpsPerIP is the number of referred sign ups per referrer and IP allowed
+		// within Window before the action applies.
+		MaxSignUpsPerIP int           `yaml:"maxSignUpsPerIP" env-default:"3"`
+		Window          time.Duration `yaml:"window" env-default:"24h"`
+		// Reject fails the sign up once the threshold is exceeded. Otherwise the referral
+		// is recorded as flagged and not rewarded.
+		Reject bool `yaml:"reject"`
+	}
+
+	CleanupConfig struct {
+		Interval  time.Duration `yaml:"interval" env-default:"1h"`
+		BatchSize int           `yaml:"batchSize" env-default:"1000"`
+	}
+
+	// CacheConfig holds the lifetimes of the entries cached in Redis.
+	CacheConfig struct {
+		// KeyPrefix is prepended to every Redis key, e.g. "link-base" for "link-base:session:...".
+		KeyPrefix string `yaml:"keyPrefix" env:"CACHE_KEY_PREFIX"`
+		// ReferralCodeTTL caps how long a referral code is cached. Codes outliving their
+		// cache entry are looked up in Postgres. 0 caches a code until it expires.
+		ReferralCodeTTL time.Duration `yaml:"referralCodeTTL" env:"CACHE_REFERRAL_CODE_TTL"`
+		// UserTTL is how long a user looked up by email is cached, at most 5m. The cached
+		// user includes the password hash and salt, which sign ins verify against.
+		UserTTL time.Duration `yaml:"userTTL" env:"CACHE_USER_TTL" env-default:"1m"`
+		// FailOpen treats Redis errors of the referral code cache, rate limiting, sign in
+		// lockout and counters as misses. Token revocation and sessions always fail closed.
+		FailOpen bool `yaml:"failOpen" env:"CACHE_FAIL_OPEN"`
+	}
+
+	// HealthConfig configures the background monitor of the dependencies.
+	HealthConfig struct {
+		Monitor  bool          `yaml:"monitor" env:"HEALTH_MONITOR"`
+		Interval time.Duration `yaml:"interval" env-default:"30s"`
+		Timeout  time.Duration `yaml:"timeout" env-default:"2s"`
+	}
+
+	MetricsConfig struct {
+		// Enabled serves the metrics and records the database query metrics.
+		Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED" env-default:"true"`
+		Port    string `yaml:"port"`
+	}
+
+	LogConfig struct {
+		Format    string `yaml:"format" env:"LOG_FORMAT" env-default:"text"`
+		Level     string `yaml:"level" env:"LOG_LEVEL" env-default:"debug"`
+		AddSource bool   `yaml:"addSource" env:"LOG_ADD_SOURCE"`
+	}
+
+	TracingConfig struct {
+		Enabled     bool    `yaml:"enabled" env:"TRACING_ENABLED"`
+		Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" env-default:"localhost:4318"`
+		Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`
+		ServiceName string  `yaml:"serviceName" env:"OTEL_SERVICE_NAME" env-default:"link-base"`
+		SampleRatio float64 `yaml:"sampleRatio" env-default:"1"`
+	}
+
+	CORSConfig struct {
+		AllowedOrigins   []string      `yaml:"allowedOrigins"`
+		AllowedMethods   []string      `yaml:"allowedMethods"`
+		AllowedHeaders   []string      `yaml:"allowedHeaders"`
+		AllowCredentials bool          `yaml:"allowCredentials"`
+		MaxAge           time.Duration `yaml:"maxAge" env-default:"12h"`
+	}
+
+	RateLimitConfig struct {
+		Enabled bool        `yaml:"enabled"`
+		Default LimitConfig `yaml:"default"`
+		Auth    LimitConfig `yaml:"auth"`
+		User    LimitConfig `yaml:"user"`
+	}
+
+	LimitConfig struct {
+		Requests int           `yaml:"requests"`
+		Window   time.Duration `yaml:"window"`
+	}
+
+	HashConfig struct {
+		Algorithm string       `yaml:"algorithm" env-default:"sha1"`
+		Salt      string       `yaml:"salt" env:"PASSWORD_SALT" env-default:"lolkek"`
+		Bcrypt    BcryptConfig `yaml:"bcrypt"`
+		Argon2    Argon2Config `yaml:"argon2"`
+	}
+
+	BcryptConfig struct {
+		Cost int `yaml:"cost" env-default:"10"`
+	}
+
+	Argon2Config struct {
+		Time       uint32 `yaml:"time" env-default:"1"`
+		Memory     uint32 `yaml:"memory" env-default:"65536"`
+		Threads    uint8  `yaml:"threads" env-default:"4"`
+		SaltLength uint32 `yaml:"saltLength" env-default:"16"`
+		KeyLength  uint32 `yaml:"keyLength" env-default:"32"`
+	}
+
+	EmailConfig struct {
+		Driver    string      `yaml:"driver" env:"EMAIL_DRIVER" env-default:"smtp"`
+		QueueSize int         `yaml:"queueSize" env-default:"100"`
+		Workers   int         `yaml:"workers" env-default:"2"`
+		Retry     RetryConfig `yaml:"retry"`
 	}
 
 	SMPTConfig struct {
-		SMPTHost     string `yaml:"smptHost"`
-		SMPTPort     string `yaml:"smptPort"`
-		SMPTUser     string `yaml:"smptUser"`
-		SMPTPassword string `yaml:"smptPassword"`
+		SMPTHost     string `yaml:"smptHost" env:"SMTP_HOST"`
+		SMPTPort     string `yaml:"smptPort" env:"SMTP_PORT"`
+		SMPTUser     string `yaml:"smptUser" env:"SMTP_USER"`
+		SMPTPassword string `yaml:"smptPassword" env:"SMTP_PASSWORD"`
+		From         string `yaml:"from" env:"SMTP_FROM"`
 	}
 )
 
-// MustLoad loads the configuration from the file specified in the CONFIG_PATH environment variable.
-// It terminates the program with a fatal log if CONFIG_PATH is not set, the file cannot be found,
-// or if there is an error reading the configuration.
+// MustLoad loads the configuration from the file specified in the CONFIG_PATH environment variable
+// and the environment, which takes precedence over the file.
+//
+// Both CONFIG_PATH and ENV_PATH, a dotenv file loaded into the environment, are optional; without
+// them the configuration is read from the environment variables and defaults only. It terminates
+// the program with a fatal log if a given file cannot be found, or if there is an error reading
+// the configuration.
 func MustLoad() *Config {
+	var cfg Config
+
+	if envPath := os.Getenv("ENV_PATH"); envPath != "" {
+		if err := cleanenv.ReadConfig(envPath, &cfg); err != nil {
+			log.Fatalf("Error reading environment variables: %s", err)
+		}
+	}
+
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
-		log.Fatalf("CONFIG_PATH IS NOT SET")
-	}
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			log.Fatalf("Error reading environment variables: %s", err)
+		}
 
-	envPath := os.Getenv("ENV_PATH")
-	if envPath == "" {
-		log.Fatalf("ENV_PATH IS NOT SET")
+		return &cfg
 	}
 
 	if _, err := os.Stat(configPath); err != nil {
 		log.Fatalf("Configuration file not found: %s", configPath)
 	}
 
-	var cfg Config
-	if err := cleanenv.ReadConfig(envPath, &cfg); err != nil {
-		log.Fatalf("Error reading environment variables: %s", err)
-	}
-
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		log.Fatalf("Error reading configuration: %s", err)
 	}
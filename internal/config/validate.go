@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxUserCacheTTL caps how long users, including their password hash, are cached, so a
+// changed password or ban missed by the cache invalidation takes effect quickly.
+const maxUserCacheTTL = 5 * time.Minute
+
+// Validate checks that the configuration is complete and consistent.
+//
+// Every problem found is reported, so a misconfigured deployment can be fixed in one go
+// instead of failing on the first missing value.
+//
+// Returns:
+//   - error: An error listing every invalid field, or nil if the configuration is valid.
+func (c *Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.HTTP.Port), "http.port: %q is not a valid port", c.HTTP.Port)
+	check(c.HTTP.ReadTimeout >= 0, "http.readTimeout: must not be negative")
+	check(c.HTTP.ReadHeaderTimeout >= 0, "http.readHeaderTimeout: must not be negative")
+	check(c.HTTP.WriteTimeout >= 0, "http.writeTimeout: must not be negative")
+	check(c.HTTP.IdleTimeout >= 0, "http.idleTimeout: must not be negative")
+	check(c.HTTP.ShutdownDelay >= 0, "http.shutdownDelay: must not be negative")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdownTimeout: must be positive")
+	check((c.HTTP.TLS.CertFile == "") == (c.HTTP.TLS.KeyFile == ""),
+		"http.tls: certFile and keyFile must be set together")
+	for _, proxy := range c.HTTP.TrustedProxies {
+		check(validIPOrCIDR(proxy), "http.trustedProxies: %q is not an IP or CIDR", proxy)
+	}
+	check(!c.CORS.AllowCredentials || !slices.Contains(c.CORS.AllowedOrigins, "*"),
+		"cors.allowedOrigins: \"*\" can't be combined with cors.allowCredentials")
+
+	check(c.Postgres.Host != "", "postgres.host: must be set")
+	check(validPort(c.Postgres.Port), "postgres.port: %q is not a valid port", c.Postgres.Port)
+	check(c.Postgres.Database != "", "postgres.database: must be set")
+	check(c.Postgres.QueryTimeout >= 0, "postgres.queryTimeout: must not be negative")
+	errs = append(errs, validateRetry("postgres.retry", c.Postgres.Retry)...)
+
+	switch c.Redis.Mode {
+	case "", "standalone":
+		check(validAddr(c.Redis.Address), "redis.addr: %q is not a host:port address", c.Redis.Address)
+	case "sentinel":
+		check(c.Redis.MasterName != "", "redis.masterName: must be set in sentinel mode")
+		check(len(c.Redis.SentinelAddrs) > 0, "redis.sentinelAddrs: must be set in sentinel mode")
+		for _, addr := range c.Redis.SentinelAddrs {
+			check(validAddr(addr), "redis.sentinelAddrs: %q is not a host:port address", addr)
+		}
+	case "cluster":
+		check(len(c.Redis.ClusterAddrs) > 0, "redis.clusterAddrs: must be set in cluster mode")
+		for _, addr := range c.Redis.ClusterAddrs {
+			check(validAddr(addr), "redis.clusterAddrs: %q is not a host:port address", addr)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("redis.mode: unknown mode %q", c.Redis.Mode))
+	}
+	errs = append(errs, validateRetry("redis.retry", c.Redis.Retry)...)
+
+	check(c.JWT.AccessTokenTTL > 0, "jwt.accessTokenTTL: must be positive")
+	check(c.JWT.RefreshTokenTTL > 0, "jwt.refreshTokenTTL: must be positive")
+	switch c.JWT.Algorithm {
+	case "", "HS256":
+		if len(c.JWT.SigningKeys) == 0 {
+			check(c.JWT.SigningKey != "", "jwt.signingKey: must be set (SIGNING_KEY)")
+		}
+		for kid, key := range c.JWT.SigningKeys {
+			check(key != "", "jwt.signingKeys: empty key for key id %q", kid)
+		}
+	case "RS256":
+		check(c.JWT.PrivateKeyPath != "", "jwt.privateKeyPath: must be set for RS256")
+	default:
+		errs = append(errs, fmt.Errorf("jwt.algorithm: unsupported algorithm %q", c.JWT.Algorithm))
+	}
+
+	check(c.Auth.PasswordPolicy.MinLength > 0, "auth.passwordPolicy.minLength: must be positive")
+	check(c.Auth.SessionStore == "postgres" || c.Auth.SessionStore == "redis",
+		"auth.sessionStore: must be postgres or redis, got %q", c.Auth.SessionStore)
+	check(c.Auth.MaxSessions >= 0, "auth.maxSessions: must not be negative")
+	if key := c.Auth.TwoFactor.EncryptionKey; key != "" {
+		raw, err := hex.DecodeString(key)
+		check(err == nil && len(raw) == 32, "auth.twoFactor.encryptionKey: must be 32 hex-encoded bytes (TOTP_ENCRYPTION_KEY)")
+	}
+	check(c.Auth.TwoFactor.ChallengeTTL > 0, "auth.twoFactor.challengeTTL: must be positive")
+	check(c.Auth.TwoFactor.Skew >= 0, "auth.twoFactor.skew: must not be negative")
+
+	check(c.Referral.MaxActiveCodes > 0, "referral.maxActiveCodes: must be positive")
+	check(c.Referral.MinTTL <= c.Referral.MaxTTL, "referral.minTTL: must not exceed referral.maxTTL")
+	check(c.Referral.RewardPoints >= 0, "referral.rewardPoints: must not be negative")
+	if c.Referral.Webhook.URL != "" {
+		if u, err := url.Parse(c.Referral.Webhook.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("referral.webhook.url: %q is not an absolute URL", c.Referral.Webhook.URL))
+		}
+		check(c.Referral.Webhook.Secret != "", "referral.webhook.secret: must be set (REFERRAL_WEBHOOK_SECRET)")
+		check(c.Referral.Webhook.Timeout > 0, "referral.webhook.timeout: must be positive")
+		check(c.Referral.Webhook.QueueSize > 0, "referral.webhook.queueSize: must be positive")
+		errs = append(errs, validateRetry("referral.webhook.retry", c.Referral.Webhook.Retry)...)
+	}
+	if c.Referral.Fraud.Enabled {
+		check(c.Referral.Fraud.MaxSignUpsPerIP > 0, "referral.fraud.maxSignUpsPerIP: must be positive")
+		check(c.Referral.Fraud.Window > 0, "referral.fraud.window: must be positive")
+	}
+	if u, err := url.Parse(c.Referral.LinkURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("referral.linkURL: %q is not an absolute URL", c.Referral.LinkURL))
+	}
+
+	check(c.Email.Driver == "smtp" || c.Email.Driver == "noop",
+		"email.driver: must be smtp or noop, got %q", c.Email.Driver)
+	check(c.Email.QueueSize > 0, "email.queueSize: must be positive")
+	check(c.Email.Workers > 0, "email.workers: must be positive")
+	errs = append(errs, validateRetry("email.retry", c.Email.Retry)...)
+
+	check(c.Cleanup.Interval > 0, "cleanup.interval: must be positive")
+	check(c.Cleanup.BatchSize > 0, "cleanup.batchSize: must be positive")
+	check(!strings.ContainsAny(c.Cache.KeyPrefix, " \t\r\n"), "cache.keyPrefix: must not contain whitespace")
+	check(c.Cache.ReferralCodeTTL >= 0, "cache.referralCodeTTL: must not be negative")
+	check(c.Cache.UserTTL > 0 && c.Cache.UserTTL <= maxUserCacheTTL,
+		"cache.userTTL: must be positive and at most %s", maxUserCacheTTL)
+	if c.Health.Monitor {
+		check(c.Health.Interval > 0, "health.interval: must be positive")
+		check(c.Health.Timeout > 0, "health.timeout: must be positive")
+	}
+
+	if c.RateLimit.Enabled {
+		check(validLimit(c.RateLimit.Default), "rateLimit.default: requests and window must be positive")
+		check(validLimit(c.RateLimit.Auth), "rateLimit.auth: requests and window must be positive")
+		check(validLimit(c.RateLimit.User), "rateLimit.user: requests and window must be positive")
+	}
+
+	if c.Metrics.Port != "" {
+		check(validPort(c.Metrics.Port), "metrics.port: %q is not a valid port", c.Metrics.Port)
+	}
+
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: must be text or json, got %q", c.Log.Format)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: %q is not a valid level", c.Log.Level)
+
+	if c.Tracing.Enabled {
+		check(validAddr(c.Tracing.Endpoint), "tracing.endpoint: %q is not a host:port address", c.Tracing.Endpoint)
+		check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio: must be between 0 and 1")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateRetry checks the retry settings of a connection.
+func validateRetry(field string, cfg RetryConfig) []error {
+	var errs []error
+
+	if cfg.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("%s.maxAttempts: must be at least 1", field))
+	}
+
+	if cfg.BaseDelay < 0 {
+		errs = append(errs, fmt.Errorf("%s.baseDelay: must not be negative", field))
+	}
+
+	return errs
+}
+
+// validPort reports whether port is a TCP port number.
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// validAddr reports whether addr looks like a host:port address.
+func validAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	return err == nil && host != "" && validPort(port)
+}
+
+// validIPOrCIDR reports whether s is an IP address or a CIDR range.
+func validIPOrCIDR(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// validLimit reports whether a rate limit allows requests in a non-empty window.
+func validLimit(limit LimitConfig) bool {
+	return limit.Requests > 0 && limit.Window > 0
+}
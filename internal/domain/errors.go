@@ -0,0 +1,36 @@
+package domain
+
+import "errors"
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrTokenNotFound   = errors.New("token not found or expired")
+	ErrUserNotVerified = errors.New("email is not verified")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEmailInUse         = errors.New("email already in use")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
+	ErrUserBanned         = errors.New("account is banned")
+	ErrUserNotFound       = errors.New("user not found")
+
+	ErrTwoFactorUnavailable = errors.New("two-factor authentication is not configured")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+	ErrEmailUnavailable = errors.New("email can't be sent right now, try again later")
+
+	ErrQueryTimeout = errors.New("database query timed out")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+
+	ErrReferralCodeLimit     = errors.New("active referral code limit reached")
+	ErrReferralCodeTaken     = errors.New("referral code already in use")
+	ErrInvalidReferralAlias  = errors.New("referral alias must be 4-32 letters, digits, '-' or '_'")
+	ErrInvalidAnalyticsRange = errors.New("invalid analytics range or bucket")
+	ErrInvalidReferralTTL    = errors.New("invalid referral code ttl")
+	ErrReferralCodeNotFound  = errors.New("referral code not found")
+	ErrReferralCodeNotOwned  = errors.New("referral code belongs to another user")
+	ErrReferralSuspicious    = errors.New("too many referred sign ups from this address")
+)
@@ -0,0 +1,21 @@
+package domain
+
+import "strings"
+
+// Password policy rules reported by PasswordPolicyError.
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleUpper     = "uppercase"
+	PasswordRuleLower     = "lowercase"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSymbol    = "symbol"
+)
+
+// PasswordPolicyError is returned when a password doesn't satisfy the password policy.
+type PasswordPolicyError struct {
+	Rules []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not satisfy policy: " + strings.Join(e.Rules, ", ")
+}
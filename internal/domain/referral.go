@@ -9,4 +9,8 @@ type Referral struct {
 	ReferralCode string        `db:"code"`
 	UserId       uuid.UUID     `db:"user_id"`
 	TTL          time.Duration `db:"ttl"`
+	MaxUses      int           `db:"max_uses"`
+	Uses         int           `db:"uses"`
+	CreatedAt    time.Time     `db:"created_at"`
+	UpdatedAt    time.Time     `db:"updated_at"`
 }
@@ -1,8 +1,29 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type ReferralUser struct {
 	UserID   uuid.UUID `json:"user_id" db:"user_id"`
 	Referral uuid.UUID `json:"referral" db:"referral"`
+	Code     string    `json:"code" db:"code"`
+	// IP is the address the referred user signed up from.
+	IP string `json:"signup_ip" db:"signup_ip"`
+	// Flagged marks a referral that exceeded the sign ups per IP threshold.
+	Flagged bool `json:"flagged" db:"flagged"`
+}
+
+// ReferralCodeCount is the number of users that signed up with a referral code.
+type ReferralCodeCount struct {
+	Code  string `json:"code" db:"code"`
+	Count int    `json:"count" db:"count"`
+}
+
+// ReferralBucket is the number of referred sign ups within a time bucket.
+type ReferralBucket struct {
+	Bucket time.Time `json:"bucket" db:"bucket"`
+	Count  int       `json:"count" db:"count"`
 }
@@ -6,7 +6,12 @@ import (
 )
 
 type RefreshToken struct {
+	SessionID    uuid.UUID `db:"session_id"`
 	UserID       uuid.UUID `db:"user_id"`
 	RefreshToken string    `db:"refresh_token"`
+	UserAgent    string    `db:"user_agent"`
+	IP           string    `db:"ip"`
 	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
 }
@@ -1,9 +1,28 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 type User struct {
-	UserId       uuid.UUID `db:"user_id"`
-	Email        string    `db:"email"`
-	PasswordHash string    `db:"password_hash"`
+	UserId       uuid.UUID  `db:"user_id"`
+	Email        string     `db:"email"`
+	PasswordHash string     `db:"password_hash"`
+	Salt         string     `db:"salt"`
+	Role         string     `db:"role"`
+	IsVerified   bool       `db:"is_verified"`
+	TOTPSecret   string     `db:"totp_secret"`
+	TOTPEnabled  bool       `db:"totp_enabled"`
+	IsBanned     bool       `db:"is_banned"`
+	Points       int64      `db:"points"`
+	LastLoginAt  *time.Time `db:"last_login_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
 }
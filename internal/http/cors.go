@@ -0,0 +1,63 @@
+package http
+
+import (
+	"link-base/internal/config"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMiddleware returns a middleware that adds the CORS headers for the allowed origins.
+//
+// Requests from other origins get no CORS headers, so browsers block them. Origins only
+// allowed by "*" get the literal wildcard and never the credentials header, so arbitrary
+// sites can't make credentialed requests. Preflight OPTIONS requests are answered with 204
+// without reaching the routes.
+//
+// Parameters:
+//   - cfg: A CORSConfig struct containing the allowed origins, methods and headers,
+//     whether credentials are allowed and how long preflight results may be cached.
+//
+// Returns:
+//   - gin.HandlerFunc: The CORS middleware.
+func corsMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	allowedOrigins := make(map[string]struct{}, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		allowedOrigins[origin] = struct{}{}
+	}
+	_, allowAll := allowedOrigins["*"]
+
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			return
+		}
+
+		_, listed := allowedOrigins[origin]
+		switch {
+		case listed:
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+			if cfg.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		case allowAll:
+			c.Header("Access-Control-Allow-Origin", "*")
+		default:
+			return
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+		}
+	}
+}
@@ -1,25 +1,71 @@
 package http
 
 import (
+	"link-base/internal/cache"
+	"link-base/internal/config"
 	v1 "link-base/internal/http/v1"
+	v2 "link-base/internal/http/v2"
 	"link-base/internal/service"
 	"link-base/pkg/auth"
+	"log/slog"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	_ "link-base/docs"
 )
 
+// Deps holds the dependencies of the HTTP handlers.
+type Deps struct {
+	Service      *service.Service
+	TokenManager auth.TokenManager
+	Limiter      cache.RateLimiter
+	Logger       *slog.Logger
+	DB           *sqlx.DB
+	Replica      *sqlx.DB
+	Redis        redis.UniversalClient
+	RateLimit    config.RateLimitConfig
+	CORS         config.CORSConfig
+	Metrics      config.MetricsConfig
+	Tracing      config.TracingConfig
+	// TrustedProxies are the proxies allowed to set the client IP via forwarding headers.
+	TrustedProxies []string
+}
+
 type Handler struct {
 	service      *service.Service
 	tokenManager auth.TokenManager
+	limiter      cache.RateLimiter
+	logger       *slog.Logger
+	db           *sqlx.DB
+	replica      *sqlx.DB
+	redis        redis.UniversalClient
+	rateLimit    config.RateLimitConfig
+	cors         config.CORSConfig
+	metrics      config.MetricsConfig
+	tracing      config.TracingConfig
+	proxies      []string
+	draining     atomic.Bool
 }
 
-func NewHandler(service *service.Service, tokenManager auth.TokenManager) *Handler {
+func NewHandler(deps Deps) *Handler {
 	return &Handler{
-		service:      service,
-		tokenManager: tokenManager,
+		service:      deps.Service,
+		tokenManager: deps.TokenManager,
+		limiter:      deps.Limiter,
+		logger:       deps.Logger,
+		db:           deps.DB,
+		replica:      deps.Replica,
+		redis:        deps.Redis,
+		rateLimit:    deps.RateLimit,
+		cors:         deps.CORS,
+		metrics:      deps.Metrics,
+		tracing:      deps.Tracing,
+		proxies:      deps.TrustedProxies,
 	}
 }
 
@@ -27,12 +73,23 @@ func NewHandler(service *service.Service, tokenManager auth.TokenManager) *Handl
 //
 //   - /swagger/*any: Swagger UI
 //   - /ping: Returns "pong" to test the server is up.
+//   - /health, /health/ready: Liveness and readiness probes.
+//   - /metrics: Prometheus metrics, unless they are disabled or served on a separate port.
 func (h *Handler) Init() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+
+	// The proxies are validated with the configuration, so this only fails on a bug.
+	if err := router.SetTrustedProxies(h.proxies); err != nil {
+		h.logger.Error("failed to set trusted proxies", slog.String("reason", err.Error()))
+	}
 
 	router.Use(
+		requestIdMiddleware,
+		otelgin.Middleware(h.tracing.ServiceName),
 		gin.Recovery(),
-		gin.Logger())
+		loggerMiddleware(h.logger),
+		metricsMiddleware,
+		corsMiddleware(h.cors))
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.NewHandler()))
 
@@ -40,6 +97,12 @@ func (h *Handler) Init() *gin.Engine {
 		c.String(200, "pong")
 	})
 
+	h.initHealth(router)
+
+	if h.metrics.Enabled && h.metrics.Port == "" {
+		router.GET("/metrics", gin.WrapH(MetricsHandler()))
+	}
+
 	h.initAPI(router)
 
 	return router
@@ -47,12 +110,32 @@ func (h *Handler) Init() *gin.Engine {
 
 // initAPI sets up routes for the API endpoints under /api.
 //
-// It is a thin wrapper around v1.Handler.Init() that initializes the v1 API
-// endpoints and sets them up under the /api group.
+// It is a thin wrapper around v1.Handler.Init() and v2.Handler.Init() that
+// initializes the versioned API endpoints and sets them up under the /api group.
 func (h *Handler) initAPI(router *gin.Engine) {
-	handlerV1 := v1.NewHandler(h.service, h.tokenManager)
+	handlerV1 := v1.NewHandler(v1.Deps{
+		Service:      h.service,
+		TokenManager: h.tokenManager,
+		Limiter:      h.limiter,
+		Logger:       h.logger,
+		RateLimit:    h.rateLimit,
+	})
+	handlerV2 := v2.NewHandler(v2.Deps{
+		Service:   h.service,
+		Limiter:   h.limiter,
+		Logger:    h.logger,
+		RateLimit: h.rateLimit,
+	})
 	api := router.Group("/api")
 	{
 		handlerV1.Init(api)
+		handlerV2.Init(api)
 	}
 }
+
+// Drain marks the service as shutting down. From then on the readiness probe
+// fails, so load balancers stop routing new requests to this instance while the
+// in-flight ones complete.
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
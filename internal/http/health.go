@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"link-base/pkg/database"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	Postgres string `json:"postgres,omitempty"`
+	Replica  string `json:"replica,omitempty"`
+	Redis    string `json:"redis,omitempty"`
+}
+
+// initHealth sets up the liveness and readiness probes.
+//
+//   - /health: Returns 200 as long as the process serves requests.
+//   - /health/ready: Returns 200 if Postgres, its read replica and Redis are reachable and
+//     503 otherwise, or while the service is draining before shutdown. The response names
+//     the state of every dependency; the errors themselves are only logged, since they can
+//     reveal hosts and driver details.
+func (h *Handler) initHealth(router *gin.Engine) {
+	router.GET("/health", h.health)
+	router.GET("/health/ready", h.ready)
+}
+
+func (h *Handler) health(c *gin.Context) {
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+func (h *Handler) ready(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	res := healthResponse{Status: "ok"}
+	status := http.StatusOK
+
+	for _, check := range database.Checks(h.db, h.replica, h.redis) {
+		state := "ok"
+		if err := check.Ping(ctx); err != nil {
+			h.logger.ErrorContext(ctx, "readiness check failed",
+				slog.String("dependency", check.Name),
+				slog.String("reason", err.Error()))
+
+			state = "unavailable"
+			res.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+
+		switch check.Name {
+		case "postgres":
+			res.Postgres = state
+		case "replica":
+			res.Replica = state
+		case "redis":
+			res.Redis = state
+		}
+	}
+
+	c.JSON(status, res)
+}
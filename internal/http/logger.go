@@ -0,0 +1,45 @@
+package http
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loggerMiddleware returns a middleware that logs every request once it has been handled.
+//
+// Only the method, path, status, latency, client IP and request ID are logged. Neither request
+// bodies nor query strings are, since they may carry passwords or tokens. Requests that failed
+// with 4xx are logged at warn and 5xx at error level.
+//
+// Parameters:
+//   - logger: A pointer to a slog logger.
+//
+// Returns:
+//   - gin.HandlerFunc: The logging middleware.
+func loggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"link-base/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsMiddleware records the count, latency and in-flight number of requests.
+//
+// Requests are labeled by their route template rather than the raw path, so path
+// parameters don't blow up the number of series; unmatched requests share one label.
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	metrics.HTTPRequestsInFlight.Inc()
+	defer metrics.HTTPRequestsInFlight.Dec()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+}
+
+// MetricsHandler returns the handler exposing the Prometheus metrics, so it can be
+// mounted on the API router or served on a separate internal port.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"link-base/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIdCtx = "requestId"
+
+// requestIdMiddleware assigns every request an ID, taken from the X-Request-ID header or
+// generated if the header is missing.
+//
+// The ID is stored in the Gin context, echoed in the response header and added to the
+// request context, so the services can log it.
+func requestIdMiddleware(c *gin.Context) {
+	id := c.GetHeader(requestid.Header)
+	if id == "" || len(id) > 128 {
+		id = uuid.NewString()
+	}
+
+	c.Set(requestIdCtx, id)
+	c.Header(requestid.Header, id)
+	c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+}
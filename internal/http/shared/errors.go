@@ -0,0 +1,72 @@
+package shared
+
+import (
+	"errors"
+	"link-base/internal/domain"
+	"net/http"
+)
+
+// InternalError returns the status code and message of an unexpected error: 504 if a
+// database query timed out, 500 otherwise.
+//
+// Parameters:
+//   - err: The error returned by the service.
+//
+// Returns:
+//   - int: The HTTP status code.
+//   - string: The message of the response.
+func InternalError(err error) (int, string) {
+	if errors.Is(err, domain.ErrQueryTimeout) {
+		return http.StatusGatewayTimeout, domain.ErrQueryTimeout.Error()
+	}
+
+	return http.StatusInternalServerError, err.Error()
+}
+
+// SignUpError returns the status code and message of an error returned by the sign up.
+//
+// Password policy violations are not mapped, since every API version reports their rules
+// in its own details type.
+//
+// Parameters:
+//   - err: The error returned by the sign up.
+//
+// Returns:
+//   - int: The HTTP status code.
+//   - string: The message of the response.
+func SignUpError(err error) (int, string) {
+	switch {
+	case errors.Is(err, domain.ErrEmailInUse):
+		// The message doesn't name the email, so the response reveals no more than the status code.
+		return http.StatusConflict, "an account with these details already exists"
+	case errors.Is(err, domain.ErrReferralSuspicious):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, domain.ErrReferralCodeNotFound):
+		return http.StatusBadRequest, domain.ErrReferralCodeNotFound.Error()
+	default:
+		return InternalError(err)
+	}
+}
+
+// SignInError returns the status code and message of an error returned by the sign in.
+//
+// Unknown emails get the same answer as wrong passwords, so accounts can't be enumerated.
+//
+// Parameters:
+//   - err: The error returned by the sign in.
+//
+// Returns:
+//   - int: The HTTP status code.
+//   - string: The message of the response.
+func SignInError(err error) (int, string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials) || errors.Is(err, domain.ErrUserNotFound):
+		return http.StatusUnauthorized, domain.ErrInvalidCredentials.Error()
+	case errors.Is(err, domain.ErrUserNotVerified), errors.Is(err, domain.ErrUserBanned):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, domain.ErrAccountLocked):
+		return http.StatusTooManyRequests, err.Error()
+	default:
+		return InternalError(err)
+	}
+}
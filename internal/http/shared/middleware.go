@@ -0,0 +1,88 @@
+package shared
+
+import (
+	"fmt"
+	"link-base/internal/cache"
+	"link-base/internal/config"
+	"link-base/internal/service"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Responder aborts the request with an error response in the envelope of an API version.
+type Responder func(c *gin.Context, statusCode int, message string)
+
+// Recovery returns a middleware that recovers from panics in the handlers, logs the panic
+// with its stack and responds with a 500 in the same shape as every other error.
+//
+// Parameters:
+//   - logger: The logger of the recovered panics.
+//   - respond: The error response of the API version.
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware.
+func Recovery(logger *slog.Logger, respond Responder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.ErrorContext(c.Request.Context(), "panic recovered",
+					slog.String("reason", fmt.Sprint(p)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				respond(c, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// RateLimit returns a middleware that caps the requests of a client within a fixed window.
+//
+// The counters of different route groups are separated by name. When the limit is exceeded
+// the request is aborted with 429 and a Retry-After header.
+//
+// Parameters:
+//   - limiter: The rate limiter counting the requests.
+//   - cfg: The rate limiting configuration; the middleware is a no-op if it's disabled.
+//   - name: The name of the route group the limit applies to.
+//   - limit: The number of requests allowed per window.
+//   - client: Returns the key the requests of the client are counted for.
+//   - respond: The error response of the API version.
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware.
+func RateLimit(limiter cache.RateLimiter, cfg config.RateLimitConfig, name string, limit config.LimitConfig,
+	client func(c *gin.Context) string, respond Responder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			return
+		}
+
+		allowed, err := limiter.Allow(c.Request.Context(), name+":"+client(c), limit.Requests, limit.Window)
+		if err != nil {
+			status, message := InternalError(err)
+			respond(c, status, message)
+			return
+		}
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(limit.Window.Seconds())))
+			respond(c, http.StatusTooManyRequests, "too many requests")
+		}
+	}
+}
+
+// ClientInfo returns the user agent and IP of the client making the request.
+// Missing headers result in empty values.
+func ClientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
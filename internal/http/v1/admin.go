@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+
+	defaultAdminSearchSize = 20
+	maxAdminSearchSize     = 50
+	minAdminSearchLength   = 2
+)
+
+type adminUserResponse struct {
+	UserId     uuid.UUID `json:"userId"`
+	Email      string    `json:"email"`
+	IsVerified bool      `json:"isVerified"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type adminUserPageResponse struct {
+	Items  []adminUserResponse `json:"items"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+// initAdminRouter registers the admin routes. Every route of the group requires an
+// access token of a user with the admin role.
+func (h *Handler) initAdminRouter(api *gin.RouterGroup) {
+	admin := api.Group("/admin", h.userIdentity, h.requireRole(domain.RoleAdmin))
+	{
+		admin.GET("/users", h.adminListUsers)
+		admin.GET("/users/search", h.adminSearchUsers)
+		admin.POST("/users/:id/ban", h.adminBanUser)
+		admin.POST("/users/:id/unban", h.adminUnbanUser)
+	}
+}
+
+// @Summary List Users
+// @Security UsersAuth
+// @Tags admin
+// @Description get a page of users, newest first; requires the admin role
+// @ModuleID adminListUsers
+// @Produce  json
+// @Param limit query int false "page size, 1-200" default(50)
+// @Param offset query int false "number of users to skip" default(0)
+// @Success 200 {object} response{data=adminUserPageResponse}
+// @Failure 400,401,403 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /admin/users [get]
+func (h *Handler) adminListUsers(c *gin.Context) {
+	page, err := parsePagination(c, defaultAdminPageSize, maxAdminPageSize)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, total, err := h.service.Admin.ListUsers(c.Request.Context(), page.Limit, page.Offset)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, adminUserPageResponse{
+		Items:  newAdminUserResponses(users),
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	})
+}
+
+// @Summary Search Users
+// @Security UsersAuth
+// @Tags admin
+// @Description find users whose email starts with the query, ignoring case; requires the admin role
+// @ModuleID adminSearchUsers
+// @Produce  json
+// @Param q query string true "beginning of the email, at least 2 characters"
+// @Param limit query int false "maximum number of users, 1-50" default(20)
+// @Success 200 {object} response{data=[]adminUserResponse}
+// @Failure 400,401,403 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /admin/users/search [get]
+func (h *Handler) adminSearchUsers(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if len(query) < minAdminSearchLength {
+		newResponse(c, http.StatusBadRequest, fmt.Sprintf("q must be at least %d characters", minAdminSearchLength))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAdminSearchSize)))
+	if err != nil || limit < 1 || limit > maxAdminSearchSize {
+		newResponse(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAdminSearchSize))
+		return
+	}
+
+	users, err := h.service.Admin.SearchUsers(c.Request.Context(), query, limit)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, newAdminUserResponses(users))
+}
+
+// @Summary Ban User
+// @Security UsersAuth
+// @Tags admin
+// @Description ban a user and end all their sessions; requires the admin role
+// @ModuleID adminBanUser
+// @Produce  json
+// @Param id path string true "user ID"
+// @Success 200 {object} response
+// @Failure 400,401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /admin/users/{id}/ban [post]
+func (h *Handler) adminBanUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	adminID, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if id == adminID {
+		newResponse(c, http.StatusBadRequest, "can't ban yourself")
+		return
+	}
+
+	if err := h.service.Admin.BanUser(c.Request.Context(), id); err != nil {
+		newAdminUserError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Unban User
+// @Security UsersAuth
+// @Tags admin
+// @Description lift the ban of a user; requires the admin role
+// @ModuleID adminUnbanUser
+// @Produce  json
+// @Param id path string true "user ID"
+// @Success 200 {object} response
+// @Failure 400,401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /admin/users/{id}/unban [post]
+func (h *Handler) adminUnbanUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.service.Admin.UnbanUser(c.Request.Context(), id); err != nil {
+		newAdminUserError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// newAdminUserError maps the errors of the admin user endpoints to responses.
+func newAdminUserError(c *gin.Context, err error) {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		newResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	newInternalError(c, err)
+}
+
+// newAdminUserResponses converts users to their admin representation, which leaves out
+// password hashes and other secrets.
+func newAdminUserResponses(users []domain.User) []adminUserResponse {
+	res := make([]adminUserResponse, 0, len(users))
+	for _, user := range users {
+		res = append(res, adminUserResponse{
+			UserId:     user.UserId,
+			Email:      user.Email,
+			IsVerified: user.IsVerified,
+			CreatedAt:  user.CreatedAt,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"errors"
+	"link-base/internal/domain"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type apiKeyCreateRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=64"`
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type apiKeyCreateResponse struct {
+	apiKeyResponse
+	Key string `json:"key"`
+}
+
+func (h *Handler) initAPIKeysRouter(users *gin.RouterGroup) {
+	apiKeys := users.Group("/api-keys", h.userIdentity)
+	{
+		apiKeys.POST("", h.createAPIKey)
+		apiKeys.GET("", h.listAPIKeys)
+		apiKeys.DELETE("/:id", h.revokeAPIKey)
+	}
+}
+
+// @Summary Create API Key
+// @Security UsersAuth
+// @Tags users-api-keys
+// @Description create an API key for the current user; the key is only returned once
+// @ModuleID createAPIKey
+// @Accept  json
+// @Produce  json
+// @Param input body apiKeyCreateRequest true "API key info"
+// @Success 201 {object} response{data=apiKeyCreateResponse}
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/api-keys [post]
+func (h *Handler) createAPIKey(c *gin.Context) {
+	var inp apiKeyCreateRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	apiKey, key, err := h.service.APIKey.Create(c.Request.Context(), id, inp.Name)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusCreated, apiKeyCreateResponse{
+		apiKeyResponse: newAPIKeyResponse(apiKey),
+		Key:            key,
+	})
+}
+
+// @Summary List API Keys
+// @Security UsersAuth
+// @Tags users-api-keys
+// @Description list the API keys of the current user
+// @ModuleID listAPIKeys
+// @Produce  json
+// @Success 200 {object} response{data=[]apiKeyResponse}
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/api-keys [get]
+func (h *Handler) listAPIKeys(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	apiKeys, err := h.service.APIKey.List(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	res := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		res = append(res, newAPIKeyResponse(apiKey))
+	}
+
+	newSuccess(c, http.StatusOK, res)
+}
+
+// @Summary Revoke API Key
+// @Security UsersAuth
+// @Tags users-api-keys
+// @Description revoke an API key of the current user
+// @ModuleID revokeAPIKey
+// @Produce  json
+// @Param id path string true "API key id"
+// @Success 204
+// @Failure 400,401,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/api-keys/{id} [delete]
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	userID, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	if err := h.service.APIKey.Revoke(c.Request.Context(), userID, keyID); err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			newResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func newAPIKeyResponse(apiKey domain.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		LastUsedAt: apiKey.LastUsedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
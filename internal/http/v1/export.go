@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type exportResponse struct {
+	ExportedAt    time.Time                `json:"exportedAt"`
+	Profile       exportProfileResponse    `json:"profile"`
+	ReferralCodes []exportReferralResponse `json:"referralCodes"`
+	Referred      []uuid.UUID              `json:"referred"`
+	Sessions      []sessionResponse        `json:"sessions"`
+	APIKeys       []apiKeyResponse         `json:"apiKeys"`
+}
+
+type exportProfileResponse struct {
+	UserId           uuid.UUID  `json:"userId"`
+	Email            string     `json:"email"`
+	Role             string     `json:"role"`
+	IsVerified       bool       `json:"isVerified"`
+	TwoFactorEnabled bool       `json:"twoFactorEnabled"`
+	IsBanned         bool       `json:"isBanned"`
+	Points           int64      `json:"points"`
+	LastLoginAt      *time.Time `json:"lastLoginAt"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+type exportReferralResponse struct {
+	Code      string    `json:"code"`
+	MaxUses   int       `json:"maxUses"`
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// @Summary Export User Data
+// @Security UsersAuth
+// @Tags users
+// @Description download everything stored about the current user as a JSON file; secrets such as the password hash are left out
+// @ModuleID userExport
+// @Produce  json
+// @Success 200 {object} exportResponse
+// @Header 200 {string} Content-Disposition "attachment; filename=link-base-export-<userId>.json"
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/me/export [get]
+func (h *Handler) userExport(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	data, err := h.service.User.ExportData(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	res := exportResponse{
+		ExportedAt: time.Now().UTC(),
+		Profile: exportProfileResponse{
+			UserId:           data.User.UserId,
+			Email:            data.User.Email,
+			Role:             data.User.Role,
+			IsVerified:       data.User.IsVerified,
+			TwoFactorEnabled: data.User.TOTPEnabled,
+			IsBanned:         data.User.IsBanned,
+			Points:           data.User.Points,
+			LastLoginAt:      data.User.LastLoginAt,
+			CreatedAt:        data.User.CreatedAt,
+			UpdatedAt:        data.User.UpdatedAt,
+		},
+		ReferralCodes: make([]exportReferralResponse, 0, len(data.ReferralCodes)),
+		Referred:      data.Referred,
+		Sessions:      make([]sessionResponse, 0, len(data.Sessions)),
+		APIKeys:       make([]apiKeyResponse, 0, len(data.APIKeys)),
+	}
+
+	for _, code := range data.ReferralCodes {
+		res.ReferralCodes = append(res.ReferralCodes, exportReferralResponse{
+			Code:      code.ReferralCode,
+			MaxUses:   code.MaxUses,
+			Uses:      code.Uses,
+			CreatedAt: code.CreatedAt,
+		})
+	}
+
+	for _, session := range data.Sessions {
+		res.Sessions = append(res.Sessions, sessionResponse{
+			ID:        session.SessionID,
+			Token:     maskToken(session.RefreshToken),
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			ExpiresAt: session.ExpiresAt,
+			CreatedAt: session.CreatedAt,
+		})
+	}
+
+	for _, apiKey := range data.APIKeys {
+		res.APIKeys = append(res.APIKeys, newAPIKeyResponse(apiKey))
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="link-base-export-%s.json"`, id))
+	c.IndentedJSON(http.StatusOK, res)
+}
@@ -1,27 +1,47 @@
 package v1
 
 import (
+	"link-base/internal/cache"
+	"link-base/internal/config"
+	"link-base/internal/http/shared"
 	"link-base/internal/service"
 	"link-base/pkg/auth"
+	"log/slog"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Deps holds the dependencies of the v1 handlers.
+type Deps struct {
+	Service      *service.Service
+	TokenManager auth.TokenManager
+	Limiter      cache.RateLimiter
+	Logger       *slog.Logger
+	RateLimit    config.RateLimitConfig
+}
+
 type Handler struct {
 	service      *service.Service
 	tokenManager auth.TokenManager
+	limiter      cache.RateLimiter
+	logger       *slog.Logger
+	rateLimit    config.RateLimitConfig
 }
 
-func NewHandler(service *service.Service, tokenManager auth.TokenManager) *Handler {
+func NewHandler(deps Deps) *Handler {
 	return &Handler{
-		service:      service,
-		tokenManager: tokenManager,
+		service:      deps.Service,
+		tokenManager: deps.TokenManager,
+		limiter:      deps.Limiter,
+		logger:       deps.Logger,
+		rateLimit:    deps.RateLimit,
 	}
 }
 
 func (h *Handler) Init(api *gin.RouterGroup) {
-	v1 := api.Group("/v1")
+	v1 := api.Group("/v1", shared.Recovery(h.logger, newResponse))
 	{
 		h.initUsersRouter(v1)
+		h.initAdminRouter(v1)
 	}
 }
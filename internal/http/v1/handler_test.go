@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"link-base/internal/config"
+	"link-base/internal/domain"
+	"link-base/internal/service"
+	"link-base/pkg/auth"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newTestHandler creates a handler backed by the given services, and returns the token
+// manager that signs its access tokens.
+func newTestHandler(t *testing.T, svc *service.Service) (*Handler, *auth.Manager) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	tokenManager, err := auth.NewManager(config.JWTConfig{SigningKey: "secret"})
+	if err != nil {
+		t.Fatalf("create token manager: %v", err)
+	}
+
+	h := NewHandler(Deps{
+		Service:      svc,
+		TokenManager: tokenManager,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	return h, tokenManager
+}
+
+// newTestRouter serves the v1 API under /api backed by the given services, and returns
+// the token manager that signs its access tokens.
+func newTestRouter(t *testing.T, svc *service.Service) (*gin.Engine, *auth.Manager) {
+	t.Helper()
+
+	h, tokenManager := newTestHandler(t, svc)
+
+	router := gin.New()
+	h.Init(router.Group("/api"))
+
+	return router, tokenManager
+}
+
+// serve sends the request to the router and decodes the response envelope.
+func serve(t *testing.T, router http.Handler, req *http.Request) (*httptest.ResponseRecorder, response) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body response
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+		}
+	}
+
+	return rec, body
+}
+
+// panickingUserService panics on every sign in.
+type panickingUserService struct {
+	service.User
+}
+
+func (panickingUserService) SignIn(context.Context, service.SignInInput) (service.Tokens, error) {
+	panic("boom")
+}
+
+func TestHandler_RecoversFromPanics(t *testing.T) {
+	router, _ := newTestRouter(t, &service.Service{User: panickingUserService{}})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/sign-in",
+		strings.NewReader(`{"email":"user@example.com","password":"Password1!"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec, body := serve(t, router, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want JSON", ct)
+	}
+
+	if body.Error != "internal server error" {
+		t.Errorf("error = %q, want %q", body.Error, "internal server error")
+	}
+}
+
+// activeTokensUserService treats every access token as not revoked.
+type activeTokensUserService struct {
+	service.User
+}
+
+func (activeTokensUserService) IsTokenRevoked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+// newAccessToken signs an access token for the user.
+func newAccessToken(t *testing.T, tokenManager *auth.Manager, userID uuid.UUID) string {
+	t.Helper()
+
+	token, err := tokenManager.NewJWT(userID.String(), []string{domain.RoleUser}, time.Minute)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	return token
+}
@@ -2,8 +2,14 @@ package v1
 
 import (
 	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"link-base/pkg/auth"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,103 +17,258 @@ import (
 
 const (
 	authorizationHeader = "Authorization"
+	apiKeyHeader        = "X-API-Key"
 
-	userCtx = "id"
+	userCtx   = "id"
+	claimsCtx = "claims"
+
+	bearerScheme = "Bearer"
+)
+
+var (
+	errEmptyAuthHeader   = errors.New("authorization header is missing")
+	errInvalidAuthHeader = errors.New("authorization header must be in the format \"Bearer <token>\"")
+	errEmptyToken        = errors.New("bearer token is empty")
 )
 
 // userIdentity is a middleware that extracts the user ID from the Authorization header
 // and stores it in the request context.
 //
 // The middleware expects the Authorization header to be in the format "Bearer <token>".
-// If the header is empty or invalid, or if the token is invalid, the middleware returns
-// a 401 error with a corresponding error message.
+// If the header is empty or invalid, or if the token is invalid, expired or revoked, the
+// middleware aborts with a 401 error before the handler runs. Expired and otherwise invalid
+// tokens get the same response but are logged differently.
 //
-// The user ID is stored in the request context under the key "userId".
+// The parsed user ID is stored in the request context under the key "id" and the full
+// token claims under the key "claims". Handlers read them through getUserId, getUserRoles
+// and getTokenID instead of the context keys.
 func (h *Handler) userIdentity(c *gin.Context) {
-	id, err := h.parseAuthHeader(c)
+	claims, err := h.parseAuthHeader(c)
+	if err != nil {
+		h.rejectToken(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		h.rejectToken(c, fmt.Errorf("%w: subject is not a user ID", auth.ErrInvalidToken))
+		return
+	}
+
+	revoked, err := h.service.User.IsTokenRevoked(c.Request.Context(), claims.ID)
 	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	if revoked {
+		newResponse(c, http.StatusUnauthorized, "token is revoked")
+		return
+	}
+
+	c.Set(userCtx, userID)
+	c.Set(claimsCtx, claims)
+}
+
+// apiKeyIdentity is a middleware that authenticates the request by the API key in the
+// X-API-Key header.
+//
+// It stores the ID of the key's owner and claims carrying the owner's role under the same
+// context keys as userIdentity, so downstream handlers don't depend on how the user was
+// authenticated. The claims have no token ID, as there's no token to revoke. A missing or
+// unknown key results in a 401 error.
+func (h *Handler) apiKeyIdentity(c *gin.Context) {
+	key := c.GetHeader(apiKeyHeader)
+	if key == "" {
+		newResponse(c, http.StatusUnauthorized, "empty api key header")
+		return
+	}
+
+	user, err := h.service.APIKey.Authenticate(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidAPIKey) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrUserBanned) {
+			newResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	c.Set(userCtx, user.UserId)
+	c.Set(claimsCtx, auth.Claims{
+		Subject: user.UserId.String(),
+		Roles:   []string{user.Role},
+	})
+}
+
+// requireRole returns a middleware that aborts with 403 unless the authenticated user has
+// the given role.
+//
+// It reads the roles stored by userIdentity or apiKeyIdentity, so it must run after one
+// of them.
+//
+// Parameters:
+//   - role: The role the user must have.
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware.
+func (h *Handler) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(getUserRoles(c), role) {
+			newResponse(c, http.StatusForbidden, "insufficient role")
+			return
+		}
+	}
+}
+
+// rejectToken aborts the request with 401 after userIdentity failed to authenticate it.
+//
+// Malformed headers are reported as is. Expired and invalid tokens share one response,
+// so clients can't probe why a token was rejected, and are told apart in the logs instead.
+func (h *Handler) rejectToken(c *gin.Context, err error) {
+	ctx := c.Request.Context()
+
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		h.logger.InfoContext(ctx, "access token expired")
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrInvalidIssuer), errors.Is(err, auth.ErrInvalidAudience):
+		h.logger.WarnContext(ctx, "access token invalid", slog.String("reason", err.Error()))
+	default:
+		h.logger.InfoContext(ctx, "authorization header rejected", slog.String("reason", err.Error()))
 		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
 	}
 
-	c.Set(userCtx, id)
+	newResponse(c, http.StatusUnauthorized, "invalid or expired token")
+}
+
+// userOrAPIKeyIdentity authenticates the request by API key if the X-API-Key header is
+// present, and by the JWT in the Authorization header otherwise.
+func (h *Handler) userOrAPIKeyIdentity(c *gin.Context) {
+	if c.GetHeader(apiKeyHeader) != "" {
+		h.apiKeyIdentity(c)
+		return
+	}
+
+	h.userIdentity(c)
 }
 
 // parseAuthHeader extracts and validates the JWT token from the Authorization header.
 //
 // This function retrieves the Authorization header from the provided Gin context,
-// verifies that it is in the format "Bearer <token>", and returns the token if valid.
-// If the header is missing, improperly formatted, or the token is empty, an error is returned.
+// verifies that it is in the format "Bearer <token>", and returns the token claims if valid.
+// The scheme is matched case-insensitively and only the raw token is passed to the token
+// manager. If the header is missing, uses another scheme, or the token is empty, an error
+// is returned.
+// Token verification is delegated to the token manager, so an expired token yields auth.ErrTokenExpired
+// and any other verification failure yields auth.ErrInvalidToken.
 //
 // Parameters:
 //   - c: The Gin context for the current HTTP request.
 //
 // Returns:
-//   - string: The extracted token if the header is valid.
-//   - error: An error if the header is empty, invalid, or the token cannot be retrieved.
-func (h *Handler) parseAuthHeader(c *gin.Context) (string, error) {
-	header := c.GetHeader(authorizationHeader)
+//   - auth.Claims: The verified token claims if the header is valid.
+//   - error: An error if the header is empty, invalid, or the token cannot be verified.
+func (h *Handler) parseAuthHeader(c *gin.Context) (auth.Claims, error) {
+	header := strings.TrimSpace(c.GetHeader(authorizationHeader))
 	if header == "" {
-		return "", errors.New("empty auth header")
+		return auth.Claims{}, errEmptyAuthHeader
+	}
+
+	scheme, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, bearerScheme) {
+		return auth.Claims{}, errInvalidAuthHeader
 	}
 
-	headerParts := strings.Split(header, " ")
-	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return "", errors.New("invalid auth header")
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return auth.Claims{}, errEmptyToken
 	}
 
-	if len(headerParts[1]) == 0 {
-		return "", errors.New("token is empty")
+	if strings.ContainsAny(token, " \t") {
+		return auth.Claims{}, errInvalidAuthHeader
 	}
 
-	return h.tokenManager.Parse(headerParts[1])
+	return h.tokenManager.ParseClaims(token)
 }
 
-// getUserId retrieves the user ID from the Gin context.
+// getUserId retrieves the ID of the authenticated user from the Gin context.
 //
-// The function retrieves the value associated with the "userId" context key,
-// verifies that it is a string, and attempts to parse it as a UUID.
-// If the value is not found, is of an invalid type, or cannot be parsed as a UUID,
-// an error is returned.
+// userIdentity and apiKeyIdentity only store IDs they have verified, so this function
+// fails only if it is called on a route without an identity middleware.
 //
 // Parameters:
 //   - c: The Gin context for the current HTTP request.
 //
 // Returns:
-//   - uuid.UUID: The parsed UUID if the value is found and valid.
-//   - error: An error if the value is not found, is of an invalid type, or cannot be parsed as a UUID.
+//   - uuid.UUID: The ID of the authenticated user.
+//   - error: An error if no user ID is stored in the context.
 func getUserId(c *gin.Context) (uuid.UUID, error) {
-	return getIdByContext(c, userCtx)
+	id, ok := c.Get(userCtx)
+	if !ok {
+		return uuid.Nil, errors.New("user id not found")
+	}
+
+	userID, ok := id.(uuid.UUID)
+	if !ok {
+		return uuid.Nil, errors.New("user id is of invalid type")
+	}
+
+	return userID, nil
 }
 
-// getIdByContext retrieves the UUID value from the provided Gin context.
-//
-// The function retrieves the value associated with the provided context key,
-// verifies that it is a string, and attempts to parse it as a UUID.
-// If the value is not found, is of an invalid type, or cannot be parsed as a UUID,
-// an error is returned.
+// getClaims retrieves the claims of the authenticated user from the Gin context.
 //
 // Parameters:
 //   - c: The Gin context for the current HTTP request.
-//   - context: The key of the value to be retrieved from the context.
 //
 // Returns:
-//   - uuid.UUID: The parsed UUID if the value is found and valid.
-//   - error: An error if the value is not found, is of an invalid type, or cannot be parsed as a UUID.
-func getIdByContext(c *gin.Context, context string) (uuid.UUID, error) {
-	idFromCtx, ok := c.Get(context)
+//   - auth.Claims: The claims stored by userIdentity or apiKeyIdentity.
+//   - bool: Whether claims were found.
+func getClaims(c *gin.Context) (auth.Claims, bool) {
+	value, ok := c.Get(claimsCtx)
 	if !ok {
-		return uuid.Nil, errors.New("id not found")
+		return auth.Claims{}, false
 	}
 
-	idStr, ok := idFromCtx.(string)
-	if !ok {
-		return uuid.Nil, errors.New("id is of invalid type")
-	}
+	claims, ok := value.(auth.Claims)
 
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		return uuid.Nil, err
+	return claims, ok
+}
+
+// getTokenID retrieves the ID and expiration time of the access token used for the current request.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//
+// Returns:
+//   - string: The token ID (jti) of the access token.
+//   - time.Time: The expiration time of the token.
+//   - error: An error if the request wasn't authenticated with an access token.
+func getTokenID(c *gin.Context) (string, time.Time, error) {
+	claims, ok := getClaims(c)
+	if !ok || claims.ID == "" {
+		return "", time.Time{}, errors.New("token id not found")
 	}
 
-	return id, nil
+	return claims.ID, claims.ExpiresAt, nil
+}
+
+// getUserRoles retrieves the roles of the current user from the Gin context.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//
+// Returns:
+//   - []string: The roles of the authenticated user, or nil if there are none.
+func getUserRoles(c *gin.Context) []string {
+	claims, _ := getClaims(c)
+
+	return claims.Roles
 }
@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"link-base/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestHandler_UserIdentity(t *testing.T) {
+	h, tokenManager := newTestHandler(t, &service.Service{User: activeTokensUserService{}})
+	userID := uuid.New()
+	token := newAccessToken(t, tokenManager, userID)
+
+	router := gin.New()
+	router.GET("/", h.userIdentity, func(c *gin.Context) {
+		id, err := getUserId(c)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		newSuccess(c, http.StatusOK, id.String())
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "missing header",
+			wantStatus: http.StatusUnauthorized,
+			wantError:  errEmptyAuthHeader.Error(),
+		},
+		{
+			name:       "wrong scheme",
+			header:     "Basic " + token,
+			wantStatus: http.StatusUnauthorized,
+			wantError:  errInvalidAuthHeader.Error(),
+		},
+		{
+			name:       "token without scheme",
+			header:     token,
+			wantStatus: http.StatusUnauthorized,
+			wantError:  errInvalidAuthHeader.Error(),
+		},
+		{
+			name:       "invalid token",
+			header:     "Bearer invalid",
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid or expired token",
+		},
+		{
+			name:       "valid token",
+			header:     "Bearer " + token,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "lowercase scheme",
+			header:     "bearer " + token,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(authorizationHeader, tt.header)
+			}
+
+			rec, body := serve(t, router, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (error %q)", rec.Code, tt.wantStatus, body.Error)
+			}
+
+			if body.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", body.Error, tt.wantError)
+			}
+
+			if tt.wantStatus == http.StatusOK && body.Data != userID.String() {
+				t.Errorf("user ID = %v, want %s", body.Data, userID)
+			}
+		})
+	}
+}
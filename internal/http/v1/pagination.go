@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pagination holds the paging parameters of a list request.
+type pagination struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// parsePagination reads the limit, offset and cursor query parameters.
+//
+// A missing limit defaults to defaultLimit and a missing offset to zero. The cursor
+// is an opaque value returned by a previous page and can't be combined with an offset.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - defaultLimit: The page size used when limit is not given.
+//   - maxLimit: The largest page size a client may request.
+//
+// Returns:
+//   - pagination: The parsed paging parameters.
+//   - error: An error describing the invalid parameter, suitable for a 400 response.
+func parsePagination(c *gin.Context, defaultLimit, maxLimit int) (pagination, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > maxLimit {
+		return pagination{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return pagination{}, fmt.Errorf("offset must be a non-negative integer")
+	}
+
+	cursor := c.Query("cursor")
+	if cursor != "" && offset != 0 {
+		return pagination{}, fmt.Errorf("cursor and offset can't be used together")
+	}
+
+	return pagination{
+		Limit:  limit,
+		Offset: offset,
+		Cursor: cursor,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"link-base/internal/config"
+	"link-base/internal/http/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitMiddleware returns a middleware that caps the requests of a client within a fixed window.
+//
+// Requests are counted per user if userIdentity ran before the middleware, and per client IP
+// otherwise. The counters of different route groups are separated by name. When the limit is
+// exceeded the request is aborted with 429 and a Retry-After header.
+//
+// Parameters:
+//   - name: The name of the route group the limit applies to.
+//   - limit: The number of requests allowed per window.
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware, a no-op if rate limiting is disabled.
+func (h *Handler) rateLimitMiddleware(name string, limit config.LimitConfig) gin.HandlerFunc {
+	return shared.RateLimit(h.limiter, h.rateLimit, name, limit, rateLimitClient, newResponse)
+}
+
+// rateLimitClient returns the ID of the identified user, or the client IP of anonymous requests.
+func rateLimitClient(c *gin.Context) string {
+	if id, err := getUserId(c); err == nil {
+		return id.String()
+	}
+
+	return c.ClientIP()
+}
@@ -1,21 +1,78 @@
 package v1
 
-import "github.com/gin-gonic/gin"
+import (
+	"link-base/internal/http/shared"
+	"link-base/pkg/requestid"
 
+	"github.com/gin-gonic/gin"
+)
+
+// response is the envelope shared by every endpoint. Successful responses
+// carry data, failed ones carry error; message mirrors error for clients
+// that still read the old field.
 type response struct {
-	Message string `json:"message"`
+	Data      any    `json:"data,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestId string `json:"requestId,omitempty"`
 }
 
-// newResponse sends a JSON response with the given status code and message.
+// newResponse sends a JSON error response with the given status code and message.
 //
-// This function aborts the current HTTP request and writes a JSON response
-// using the provided statusCode and message. It sets the response payload to
-// include a message field with the specified message.
+// This function aborts the current HTTP request and writes the response
+// envelope with the error field set to the provided message and the request
+// ID taken from the request context.
 //
 // Parameters:
 //   - c: The Gin context for the current HTTP request.
 //   - statusCode: The HTTP status code to set in the response.
 //   - message: The message to include in the response payload.
 func newResponse(c *gin.Context, statusCode int, message string) {
-	c.AbortWithStatusJSON(statusCode, response{message})
+	newErrorResponse(c, statusCode, message, nil)
+}
+
+// newInternalError sends the response of an unexpected error: 504 if a database query
+// timed out, 500 otherwise.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - err: The error returned by the service.
+func newInternalError(c *gin.Context, err error) {
+	status, message := shared.InternalError(err)
+	newResponse(c, status, message)
+}
+
+// newErrorResponse sends a JSON error response that also carries details.
+//
+// It behaves like newResponse but places the given data in the envelope's
+// data field, for errors that need to describe what exactly went wrong.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - statusCode: The HTTP status code to set in the response.
+//   - message: The message to include in the response payload.
+//   - data: Additional details about the error.
+func newErrorResponse(c *gin.Context, statusCode int, message string, data any) {
+	c.AbortWithStatusJSON(statusCode, response{
+		Data:      data,
+		Error:     message,
+		Message:   message,
+		RequestId: requestid.FromContext(c.Request.Context()),
+	})
+}
+
+// newSuccess sends a JSON success response with the given status code and data.
+//
+// The data is wrapped in the response envelope together with the request ID
+// taken from the request context.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - statusCode: The HTTP status code to set in the response.
+//   - data: The payload to include in the data field.
+func newSuccess(c *gin.Context, statusCode int, data any) {
+	c.JSON(statusCode, response{
+		Data:      data,
+		RequestId: requestid.FromContext(c.Request.Context()),
+	})
 }
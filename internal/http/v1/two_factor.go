@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"errors"
+	"link-base/internal/domain"
+	"link-base/internal/http/shared"
+	"link-base/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	Challenge         string `json:"challenge"`
+}
+
+type twoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type twoFactorSignInRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required,len=6,numeric"`
+}
+
+func (h *Handler) initTwoFactorRouter(users *gin.RouterGroup) {
+	users.POST("/auth/2fa", h.rateLimitMiddleware("auth", h.rateLimit.Auth), h.userTwoFactorSignIn)
+
+	twoFactor := users.Group("/2fa", h.userIdentity)
+	{
+		twoFactor.POST("/enroll", h.userTwoFactorEnroll)
+		twoFactor.POST("/verify", h.userTwoFactorVerify)
+		twoFactor.POST("/disable", h.userTwoFactorDisable)
+	}
+}
+
+// newSignInSuccess sends the result of a first-factor sign in: the token pair, or the
+// two-factor challenge if the user has 2FA enabled.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - tokens: The result of the sign in.
+func newSignInSuccess(c *gin.Context, tokens service.Tokens) {
+	if tokens.TwoFactorChallenge != "" {
+		newSuccess(c, http.StatusOK, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			Challenge:         tokens.TwoFactorChallenge,
+		})
+		return
+	}
+
+	newSuccess(c, http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// @Summary Two-Factor SignIn
+// @Tags users-auth
+// @Description complete a sign in with the challenge returned by sign-in and a TOTP code
+// @ModuleID userTwoFactorSignIn
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorSignInRequest true "challenge and code"
+// @Success 200 {object} response{data=tokenResponse}
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/2fa [post]
+func (h *Handler) userTwoFactorSignIn(c *gin.Context) {
+	var inp twoFactorSignInRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.service.User.CompleteTwoFactor(c.Request.Context(), inp.Challenge, inp.Code, shared.ClientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTokenNotFound), errors.Is(err, domain.ErrInvalidTwoFactorCode):
+			newResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, domain.ErrUserBanned):
+			newResponse(c, http.StatusForbidden, err.Error())
+		default:
+			newInternalError(c, err)
+		}
+
+		return
+	}
+
+	newSuccess(c, http.StatusOK, tokenResponse{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+	})
+}
+
+// @Summary Enroll Two-Factor Authentication
+// @Security UsersAuth
+// @Tags users-2fa
+// @Description generate a TOTP secret; 2FA is enabled once a code is verified
+// @ModuleID userTwoFactorEnroll
+// @Produce  json
+// @Success 200 {object} response{data=twoFactorEnrollResponse}
+// @Failure 401,409 {object} response
+// @Failure 500,503 {object} response
+// @Failure default {object} response
+// @Router /users/2fa/enroll [post]
+func (h *Handler) userTwoFactorEnroll(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	enrollment, err := h.service.User.EnrollTwoFactor(c.Request.Context(), id)
+	if err != nil {
+		newTwoFactorError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, twoFactorEnrollResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+// @Summary Verify Two-Factor Enrollment
+// @Security UsersAuth
+// @Tags users-2fa
+// @Description enable 2FA by verifying a code of the enrolled secret
+// @ModuleID userTwoFactorVerify
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} response
+// @Failure 400,401,409 {object} response
+// @Failure 500,503 {object} response
+// @Failure default {object} response
+// @Router /users/2fa/verify [post]
+func (h *Handler) userTwoFactorVerify(c *gin.Context) {
+	var inp twoFactorCodeRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.ConfirmTwoFactor(c.Request.Context(), id, inp.Code); err != nil {
+		newTwoFactorError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Disable Two-Factor Authentication
+// @Security UsersAuth
+// @Tags users-2fa
+// @Description disable 2FA; requires a current code
+// @ModuleID userTwoFactorDisable
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} response
+// @Failure 400,401,409 {object} response
+// @Failure 500,503 {object} response
+// @Failure default {object} response
+// @Router /users/2fa/disable [post]
+func (h *Handler) userTwoFactorDisable(c *gin.Context) {
+	var inp twoFactorCodeRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.DisableTwoFactor(c.Request.Context(), id, inp.Code); err != nil {
+		newTwoFactorError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// newTwoFactorError maps the errors of the 2FA management endpoints to responses.
+func newTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidTwoFactorCode):
+		newResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorEnabled), errors.Is(err, domain.ErrTwoFactorNotEnrolled):
+		newResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorUnavailable):
+		newResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		newInternalError(c, err)
+	}
+}
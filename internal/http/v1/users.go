@@ -1,11 +1,27 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"link-base/internal/http/shared"
 	"link-base/internal/service"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultReferralPageSize = 20
+	maxReferralPageSize     = 100
+
+	defaultReferralQRSize = 256
+	minReferralQRSize     = 64
+	maxReferralQRSize     = 1024
 )
 
 type tokenResponse struct {
@@ -13,12 +29,37 @@ type tokenResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+type userResponse struct {
+	UserId      uuid.UUID  `json:"userId"`
+	Email       string     `json:"email"`
+	IsVerified  bool       `json:"isVerified"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type pointsResponse struct {
+	Points int64 `json:"points"`
+}
+
+type sessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Token     string    `json:"token"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type userSignUpRequest struct {
 	Email        string `json:"email" binding:"required,email,min=2,max=64"`
 	Password     string `json:"password" binding:"required,max=64"`
 	ReferralCode string `json:"referral_code"`
 }
 
+type passwordPolicyResponse struct {
+	Rules []string `json:"rules"`
+}
+
 type userSignInRequest struct {
 	Email    string `json:"email" binding:"required,email,min=2,max=64"`
 	Password string `json:"password" binding:"required,max=64"`
@@ -28,8 +69,65 @@ type refreshRequest struct {
 	Token string `json:"token" binding:"required"`
 }
 
+type introspectRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type introspectResponse struct {
+	Active bool   `json:"active"`
+	Sub    string `json:"sub,omitempty"`
+	Exp    int64  `json:"exp,omitempty"`
+	Iat    int64  `json:"iat,omitempty"`
+}
+
+type magicLinkRequest struct {
+	Email string `json:"email" binding:"required,email,min=2,max=64"`
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required,email,min=2,max=64"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,max=64"`
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email,min=2,max=64"`
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required,max=64"`
+	NewPassword string `json:"newPassword" binding:"required,max=64"`
+}
+
+type changeEmailRequest struct {
+	Email string `json:"email" binding:"required,email,min=2,max=64"`
+}
+
 type referralCreateRequest struct {
-	TTL string `json:"ttl" binding:"required"`
+	TTL     string `json:"ttl" binding:"required"`
+	Alias   string `json:"alias"`
+	MaxUses int    `json:"maxUses" binding:"min=0"`
+}
+
+type referralCodeResponse struct {
+	Code string `json:"code"`
+	Link string `json:"link"`
+}
+
+type referralPageResponse struct {
+	Items  []uuid.UUID `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+type referralStatsResponse struct {
+	Count    int                        `json:"count"`
+	Referred []uuid.UUID                `json:"referred"`
+	ByCode   []domain.ReferralCodeCount `json:"byCode"`
 }
 
 type sendEmailRequest struct {
@@ -37,17 +135,45 @@ type sendEmailRequest struct {
 }
 
 func (h *Handler) initUsersRouter(api *gin.RouterGroup) {
-	users := api.Group("/users")
+	authLimit := h.rateLimitMiddleware("auth", h.rateLimit.Auth)
+
+	users := api.Group("/users", h.rateLimitMiddleware("default", h.rateLimit.Default))
 	{
-		users.POST("/sign-up", h.userSignUp)
-		users.POST("/sign-in", h.userSignIn)
+		users.POST("/sign-up", authLimit, h.userSignUp)
+		users.POST("/sign-in", authLimit, h.userSignIn)
 		users.POST("/auth/refresh", h.userRefresh)
+		users.POST("/auth/logout", h.userIdentity, h.userLogout)
+		users.POST("/auth/logout-others", h.userIdentity, h.userLogoutOthers)
+		users.POST("/auth/introspect", h.userIntrospect)
+		users.POST("/auth/magic-link", authLimit, h.userMagicLinkRequest)
+		users.GET("/auth/magic-link/verify", authLimit, h.userMagicLinkVerify)
+		users.GET("/sessions", h.userIdentity, h.userSessions)
+		users.POST("/password-reset/request", h.userPasswordResetRequest)
+		users.POST("/password-reset/confirm", h.userPasswordResetConfirm)
+		users.GET("/verify", h.userVerify)
+		users.POST("/verify/resend", h.userResendVerification)
+		users.POST("/password/change", h.userIdentity, h.userChangePassword)
+		users.POST("/email/change", h.userIdentity, h.userChangeEmail)
+		users.GET("/email/confirm", h.userConfirmEmail)
+		users.GET("/me", h.userOrAPIKeyIdentity, h.userMe)
+		users.DELETE("/me", h.userIdentity, h.userDelete)
+		users.GET("/me/export", h.userIdentity, h.userExport)
+		users.GET("/me/points", h.userOrAPIKeyIdentity, h.userPoints)
 
-		referral := users.Group("", h.userIdentity)
+		h.initAPIKeysRouter(users)
+		h.initTwoFactorRouter(users)
+
+		referral := users.Group("", h.userOrAPIKeyIdentity, h.rateLimitMiddleware("user", h.rateLimit.User))
 		{
 			referral.GET("/referral", h.getReferrals)
+			referral.GET("/referral/stats", h.getReferralStats)
+			referral.GET("/referral/analytics", h.getReferralAnalytics)
 			referral.POST("/create-code", h.createCode)
-			referral.POST("/send-email")
+			referral.DELETE("/referral/code/:code", h.revokeCode)
+			referral.GET("/referral/code/:code/qr", h.getReferralQR)
+			referral.GET("/referral/code/:code/link", h.getReferralLink)
+			referral.POST("/referral/code/:code/regenerate", h.regenerateCode)
+			referral.POST("/send-email", h.sendEmail)
 		}
 
 	}
@@ -60,9 +186,12 @@ func (h *Handler) initUsersRouter(api *gin.RouterGroup) {
 // @Accept  json
 // @Produce  json
 // @Param input body userSignUpRequest true "sign up info"
-// @Success 201 {string} string "ok"
-// @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Param ref query string false "referral code from a shared link, used when the body has none"
+// @Success 201 {object} response{data=tokenResponse}
+// @Header 201 {string} Location "URL of the created user's profile"
+// @Failure 400 {object} response{data=passwordPolicyResponse}
+// @Failure 403,409 {object} response
+// @Failure 500,504 {object} response
 // @Failure default {object} response
 // @Router /users/sign-up [post]
 func (h *Handler) userSignUp(c *gin.Context) {
@@ -73,17 +202,33 @@ func (h *Handler) userSignUp(c *gin.Context) {
 		return
 	}
 
+	if inp.ReferralCode == "" {
+		inp.ReferralCode = c.Query("ref")
+	}
+
 	res, err := h.service.User.SignUp(c.Request.Context(), service.SignUpInput{
 		Email:        inp.Email,
 		Password:     inp.Password,
 		ReferralCode: inp.ReferralCode,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
 	})
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		var policyErr *domain.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			newErrorResponse(c, http.StatusBadRequest, policyErr.Error(), passwordPolicyResponse{
+				Rules: policyErr.Rules,
+			})
+			return
+		}
+
+		status, message := shared.SignUpError(err)
+		newResponse(c, status, message)
 		return
 	}
 
-	c.JSON(http.StatusOK, tokenResponse{
+	c.Header("Location", c.FullPath()[:strings.LastIndex(c.FullPath(), "/")]+"/me")
+	newSuccess(c, http.StatusCreated, tokenResponse{
 		AccessToken:  res.AccessToken,
 		RefreshToken: res.RefreshToken,
 	})
@@ -91,15 +236,16 @@ func (h *Handler) userSignUp(c *gin.Context) {
 
 // @Summary User SignIn
 // @Tags users-auth
-// @Description user sign in
+// @Description user sign in; users with 2FA enabled get a challenge for /users/auth/2fa instead of tokens
 // @ModuleID userSignInRequest
 // @Accept  json
 // @Produce  json
 // @Param input body userSignInRequest true "sign up info"
-// @Success 200 {object} tokenResponse
-// @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Success 200 {object} response{data=tokenResponse}
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
 // @Failure default {object} response
+// @Failure 403,429 {object} response
 // @Router /users/sign-in [post]
 func (h *Handler) userSignIn(c *gin.Context) {
 	var inp userSignInRequest
@@ -110,18 +256,18 @@ func (h *Handler) userSignIn(c *gin.Context) {
 	}
 
 	res, err := h.service.User.SignIn(c.Request.Context(), service.SignInInput{
-		Email:    inp.Email,
-		Password: inp.Password,
+		Email:     inp.Email,
+		Password:  inp.Password,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
 	})
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		status, message := shared.SignInError(err)
+		newResponse(c, status, message)
 		return
 	}
 
-	c.JSON(http.StatusOK, tokenResponse{
-		AccessToken:  res.AccessToken,
-		RefreshToken: res.RefreshToken,
-	})
+	newSignInSuccess(c, res)
 }
 
 // @Summary User Refresh Tokens
@@ -130,9 +276,9 @@ func (h *Handler) userSignIn(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param input body refreshRequest true "sign up info"
-// @Success 200 {object} tokenResponse
-// @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Success 200 {object} response{data=tokenResponse}
+// @Failure 400,401,403,404 {object} response
+// @Failure 500,504 {object} response
 // @Failure default {object} response
 // @Router /users/auth/refresh [post]
 func (h *Handler) userRefresh(c *gin.Context) {
@@ -142,43 +288,716 @@ func (h *Handler) userRefresh(c *gin.Context) {
 		return
 	}
 
-	res, err := h.service.User.RefreshTokens(c.Request.Context(), inp.Token)
+	res, err := h.service.User.RefreshTokens(c.Request.Context(), inp.Token, shared.ClientInfo(c))
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, domain.ErrUserBanned) {
+			newResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, tokenResponse{
+	newSuccess(c, http.StatusOK, tokenResponse{
 		AccessToken:  res.AccessToken,
 		RefreshToken: res.RefreshToken,
 	})
 }
 
+// @Summary User Logout
+// @Security UsersAuth
+// @Tags users-auth
+// @Description delete all refresh tokens of the current user and revoke the access token
+// @ModuleID userLogout
+// @Accept  json
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/logout [post]
+func (h *Handler) userLogout(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	jti, expiresAt, err := getTokenID(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.Logout(c.Request.Context(), id, jti, time.Until(expiresAt)); err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary User Logout Others
+// @Security UsersAuth
+// @Tags users-auth
+// @Description delete all refresh tokens of the current user except the provided one
+// @ModuleID userLogoutOthers
+// @Accept  json
+// @Produce  json
+// @Param input body refreshRequest true "current refresh token"
+// @Success 204
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/logout-others [post]
+func (h *Handler) userLogoutOthers(c *gin.Context) {
+	var inp refreshRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.LogoutOthers(c.Request.Context(), id, inp.Token); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary User Sessions
+// @Security UsersAuth
+// @Tags users-auth
+// @Description list the active sessions of the current user with masked refresh tokens
+// @ModuleID userSessions
+// @Produce  json
+// @Success 200 {object} response{data=[]sessionResponse}
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/sessions [get]
+func (h *Handler) userSessions(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sessions, err := h.service.User.ListSessions(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	res := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, sessionResponse{
+			ID:        session.SessionID,
+			Token:     maskToken(session.RefreshToken),
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			ExpiresAt: session.ExpiresAt,
+			CreatedAt: session.CreatedAt,
+		})
+	}
+
+	newSuccess(c, http.StatusOK, res)
+}
+
+// maskToken hides all but the last four characters of the token.
+func maskToken(token string) string {
+	const visible = 4
+	if len(token) <= visible {
+		return strings.Repeat("*", len(token))
+	}
+
+	return strings.Repeat("*", len(token)-visible) + token[len(token)-visible:]
+}
+
+// @Summary Introspect Access Token
+// @Tags users-auth
+// @Description report whether an access token is active and return its claims (RFC 7662)
+// @ModuleID userIntrospect
+// @Accept  json
+// @Produce  json
+// @Param input body introspectRequest true "access token"
+// @Success 200 {object} response{data=introspectResponse}
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/introspect [post]
+func (h *Handler) userIntrospect(c *gin.Context) {
+	var inp introspectRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, err := h.tokenManager.ParseClaims(inp.Token)
+	if err != nil {
+		newSuccess(c, http.StatusOK, introspectResponse{Active: false})
+		return
+	}
+
+	revoked, err := h.service.User.IsTokenRevoked(c.Request.Context(), claims.ID)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	if revoked {
+		newSuccess(c, http.StatusOK, introspectResponse{Active: false})
+		return
+	}
+
+	newSuccess(c, http.StatusOK, introspectResponse{
+		Active: true,
+		Sub:    claims.Subject,
+		Exp:    claims.ExpiresAt.Unix(),
+		Iat:    claims.IssuedAt.Unix(),
+	})
+}
+
+// @Summary Request Magic Link
+// @Tags users-auth
+// @Description email a single-use sign in link; unknown emails get the same response
+// @ModuleID userMagicLinkRequest
+// @Accept  json
+// @Produce  json
+// @Param input body magicLinkRequest true "account email"
+// @Success 200 {object} response
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/magic-link [post]
+func (h *Handler) userMagicLinkRequest(c *gin.Context) {
+	var inp magicLinkRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.User.RequestMagicLink(c.Request.Context(), inp.Email); err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Verify Magic Link
+// @Tags users-auth
+// @Description sign in with the token from a magic link; users with 2FA enabled get a challenge instead of tokens
+// @ModuleID userMagicLinkVerify
+// @Produce  json
+// @Param token query string true "magic link token"
+// @Success 200 {object} response{data=tokenResponse}
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/auth/magic-link/verify [get]
+func (h *Handler) userMagicLinkVerify(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		newResponse(c, http.StatusBadRequest, "token is empty")
+		return
+	}
+
+	res, err := h.service.User.SignInWithMagicLink(c.Request.Context(), token, shared.ClientInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrUserBanned) {
+			newResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSignInSuccess(c, res)
+}
+
+// @Summary Request Password Reset
+// @Tags users-auth
+// @Description email a password reset token; always succeeds to avoid user enumeration
+// @ModuleID userPasswordResetRequest
+// @Accept  json
+// @Produce  json
+// @Param input body passwordResetRequest true "account email"
+// @Success 200 {object} response
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/password-reset/request [post]
+func (h *Handler) userPasswordResetRequest(c *gin.Context) {
+	var inp passwordResetRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.User.RequestPasswordReset(c.Request.Context(), inp.Email); err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Confirm Password Reset
+// @Tags users-auth
+// @Description set a new password using a password reset token
+// @ModuleID userPasswordResetConfirm
+// @Accept  json
+// @Produce  json
+// @Param input body passwordResetConfirmRequest true "reset token and new password"
+// @Success 200 {object} response
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/password-reset/confirm [post]
+func (h *Handler) userPasswordResetConfirm(c *gin.Context) {
+	var inp passwordResetConfirmRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.User.ConfirmPasswordReset(c.Request.Context(), inp.Token, inp.Password); err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Verify Email
+// @Tags users-auth
+// @Description verify the account email with the token from the verification link
+// @ModuleID userVerify
+// @Produce  json
+// @Param token query string true "verification token"
+// @Success 200 {object} response
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/verify [get]
+func (h *Handler) userVerify(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		newResponse(c, http.StatusBadRequest, "token is empty")
+		return
+	}
+
+	if err := h.service.User.VerifyEmail(c.Request.Context(), token); err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Resend Verification Email
+// @Tags users-auth
+// @Description resend the verification email for a still unverified account
+// @ModuleID userResendVerification
+// @Accept  json
+// @Produce  json
+// @Param input body resendVerificationRequest true "account email"
+// @Success 200 {object} response
+// @Failure 400 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/verify/resend [post]
+func (h *Handler) userResendVerification(c *gin.Context) {
+	var inp resendVerificationRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.service.User.ResendVerification(c.Request.Context(), inp.Email); err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Change Password
+// @Security UsersAuth
+// @Tags users-auth
+// @Description change the password of the current user and log out all sessions
+// @ModuleID userChangePassword
+// @Accept  json
+// @Produce  json
+// @Param input body changePasswordRequest true "old and new password"
+// @Success 200 {object} response
+// @Failure 400,401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/password/change [post]
+func (h *Handler) userChangePassword(c *gin.Context) {
+	var inp changePasswordRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.ChangePassword(c.Request.Context(), id, inp.OldPassword, inp.NewPassword); err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Change Email
+// @Security UsersAuth
+// @Tags users-auth
+// @Description request an email change; the new address has to be confirmed via the emailed link
+// @ModuleID userChangeEmail
+// @Accept  json
+// @Produce  json
+// @Param input body changeEmailRequest true "new email"
+// @Success 200 {object} response
+// @Failure 400,401,409 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/email/change [post]
+func (h *Handler) userChangeEmail(c *gin.Context) {
+	var inp changeEmailRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.RequestEmailChange(c.Request.Context(), id, inp.Email); err != nil {
+		if errors.Is(err, domain.ErrEmailInUse) {
+			newResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Confirm Email Change
+// @Tags users-auth
+// @Description confirm the new email with the token from the confirmation link
+// @ModuleID userConfirmEmail
+// @Produce  json
+// @Param token query string true "confirmation token"
+// @Success 200 {object} response
+// @Failure 400,409 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/email/confirm [get]
+func (h *Handler) userConfirmEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		newResponse(c, http.StatusBadRequest, "token is empty")
+		return
+	}
+
+	if err := h.service.User.ConfirmEmailChange(c.Request.Context(), token); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTokenNotFound):
+			newResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, domain.ErrEmailInUse):
+			newResponse(c, http.StatusConflict, err.Error())
+		default:
+			newInternalError(c, err)
+		}
+
+		return
+	}
+
+	newSuccess(c, http.StatusOK, nil)
+}
+
+// @Summary Current User
+// @Security UsersAuth
+// @Tags users
+// @Description get the profile of the current user
+// @ModuleID userMe
+// @Produce  json
+// @Success 200 {object} response{data=userResponse}
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/me [get]
+func (h *Handler) userMe(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	user, err := h.service.User.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, userResponse{
+		UserId:      user.UserId,
+		Email:       user.Email,
+		IsVerified:  user.IsVerified,
+		LastLoginAt: user.LastLoginAt,
+		CreatedAt:   user.CreatedAt,
+	})
+}
+
+// @Summary Current User Points
+// @Security UsersAuth
+// @Tags users
+// @Description get the referral reward points of the current user
+// @ModuleID userPoints
+// @Produce  json
+// @Success 200 {object} response{data=pointsResponse}
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/me/points [get]
+func (h *Handler) userPoints(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	points, err := h.service.User.GetPoints(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, pointsResponse{Points: points})
+}
+
+// @Summary Delete Account
+// @Security UsersAuth
+// @Tags users
+// @Description deactivate the current user and delete their sessions and referral codes
+// @ModuleID userDelete
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/me [delete]
+func (h *Handler) userDelete(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.User.DeleteAccount(c.Request.Context(), id); err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // @Summary User Referrals
 // @Security UsersAuth
 // @Tags users-referral
-// @Description get user referral
+// @Description get a page of users referred by the current user
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} uuid.UUID
+// @Param limit query int false "page size" default(20) maximum(100)
+// @Param offset query int false "number of users to skip" default(0)
+// @Success 200 {object} response{data=referralPageResponse}
 // @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Failure 500,504 {object} response
 // @Failure default {object} response
 // @Router /users/referral [get]
 func (h *Handler) getReferrals(c *gin.Context) {
 	id, err := getUserId(c)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	page, err := parsePagination(c, defaultReferralPageSize, maxReferralPageSize)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, total, err := h.service.Referral.FindReferralByUserID(c.Request.Context(), id, page.Limit, page.Offset)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, referralPageResponse{
+		Items:  res,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	})
+}
+
+// @Summary User Referral Stats
+// @Security UsersAuth
+// @Tags users-referral
+// @Description get the number of users referred by the current user, the ids of the first of them and the sign ups per code
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} response{data=referralStatsResponse}
+// @Failure 400,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/stats [get]
+func (h *Handler) getReferralStats(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	referred, count, err := h.service.Referral.FindReferralByUserID(c.Request.Context(), id, maxReferralPageSize, 0)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	byCode, err := h.service.Referral.CountReferredByCode(c.Request.Context(), id)
+	if err != nil {
+		newInternalError(c, err)
+		return
+	}
+
+	if byCode == nil {
+		byCode = []domain.ReferralCodeCount{}
+	}
+
+	newSuccess(c, http.StatusOK, referralStatsResponse{
+		Count:    count,
+		Referred: referred,
+		ByCode:   byCode,
+	})
+}
+
+// @Summary User Referral Analytics
+// @Security UsersAuth
+// @Tags users-referral
+// @Description get the number of users referred by the current user per time bucket
+// @Accept  json
+// @Produce  json
+// @Param from query string true "range start (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string true "range end, exclusive (RFC 3339 or YYYY-MM-DD)"
+// @Param bucket query string false "bucket size: hour, day, week or month" default(day)
+// @Success 200 {object} response{data=[]domain.ReferralBucket}
+// @Failure 400,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/analytics [get]
+func (h *Handler) getReferralAnalytics(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	res, err := h.service.Referral.FindReferralByUserID(c.Request.Context(), id)
+	from, err := parseDate(c.Query("from"))
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusBadRequest, "invalid from: "+err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, res)
+	to, err := parseDate(c.Query("to"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+
+	res, err := h.service.Referral.Analytics(c.Request.Context(), service.ReferralAnalyticsInput{
+		UserId: id,
+		From:   from,
+		To:     to,
+		Bucket: c.DefaultQuery("bucket", "day"),
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidAnalyticsRange) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	if res == nil {
+		res = []domain.ReferralBucket{}
+	}
+
+	newSuccess(c, http.StatusOK, res)
+}
+
+// parseDate parses a query date given either as RFC 3339 or as YYYY-MM-DD.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
 }
 
 // @Summary Create Referral Code
@@ -189,9 +1008,9 @@ func (h *Handler) getReferrals(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param input body referralCreateRequest true "Create referral code request"
-// @Success 200 {string} string "referral code"
-// @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Success 200 {object} response{data=referralCodeResponse} "referral code and its shareable link"
+// @Failure 400,404,409 {object} response
+// @Failure 500,504 {object} response
 // @Failure default {object} response
 // @Router /users/create-code [post]
 func (h *Handler) createCode(c *gin.Context) {
@@ -203,26 +1022,76 @@ func (h *Handler) createCode(c *gin.Context) {
 
 	id, err := getUserId(c)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
 	ttl, err := time.ParseDuration(inp.TTL)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusBadRequest, domain.ErrInvalidReferralTTL.Error()+": "+err.Error())
 		return
 	}
 
 	res, err := h.service.Referral.CreateCode(c.Request.Context(), service.ReferralInput{
-		UserId: id,
-		TTL:    ttl,
+		UserId:  id,
+		TTL:     ttl,
+		Alias:   inp.Alias,
+		MaxUses: inp.MaxUses,
 	})
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, domain.ErrInvalidReferralAlias) || errors.Is(err, domain.ErrInvalidReferralTTL) {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrReferralCodeLimit) || errors.Is(err, domain.ErrReferralCodeTaken) {
+			newResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, referralCodeResponse{
+		Code: res.Code,
+		Link: res.Link,
+	})
+}
+
+// @Summary Revoke Referral Code
+// @Security UsersAuth
+// @Tags users-referral
+// @Description delete an active referral code of the current user before it expires
+// @ModuleID revokeCode
+// @Produce  json
+// @Param code path string true "referral code"
+// @Success 204
+// @Failure 401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/code/{code} [delete]
+func (h *Handler) revokeCode(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.Referral.RevokeCode(c.Request.Context(), id, c.Param("code")); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrReferralCodeNotFound):
+			newResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, domain.ErrReferralCodeNotOwned):
+			newResponse(c, http.StatusForbidden, err.Error())
+		default:
+			newInternalError(c, err)
+		}
+
 		return
 	}
 
-	c.JSON(http.StatusOK, res)
+	c.Status(http.StatusNoContent)
 }
 
 // @Summary Send Email
@@ -233,9 +1102,9 @@ func (h *Handler) createCode(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param input body sendEmailRequest true "Send email request"
-// @Success 200
+// @Success 202 {object} response
 // @Failure 400,404 {object} response
-// @Failure 500 {object} response
+// @Failure 500,503 {object} response
 // @Failure default {object} response
 // @Router /users/send-email [post]
 func (h *Handler) sendEmail(c *gin.Context) {
@@ -247,15 +1116,136 @@ func (h *Handler) sendEmail(c *gin.Context) {
 
 	id, err := getUserId(c)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
 	err = h.service.Referral.SendEmail(c.Request.Context(), id, inp.Email)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, domain.ErrReferralCodeNotFound) {
+			newResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrEmailUnavailable) {
+			newResponse(c, http.StatusServiceUnavailable, domain.ErrEmailUnavailable.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusAccepted, nil)
+}
+
+// @Summary Regenerate Referral Code
+// @Security UsersAuth
+// @Tags users-referral
+// @Description replace an active referral code of the current user with a fresh one keeping its ttl and max uses
+// @ModuleID regenerateCode
+// @Produce  json
+// @Param code path string true "referral code"
+// @Success 200 {object} response{data=referralCodeResponse} "new referral code and its shareable link"
+// @Failure 401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/code/{code}/regenerate [post]
+func (h *Handler) regenerateCode(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	res, err := h.service.Referral.RegenerateCode(c.Request.Context(), id, c.Param("code"))
+	if err != nil {
+		newReferralCodeError(c, err)
 		return
 	}
 
-	c.Status(http.StatusOK)
+	newSuccess(c, http.StatusOK, referralCodeResponse{
+		Code: res.Code,
+		Link: res.Link,
+	})
+}
+
+// @Summary Referral Code Link
+// @Security UsersAuth
+// @Tags users-referral
+// @Description get the shareable sign up link of an active referral code of the current user
+// @ModuleID getReferralLink
+// @Produce  json
+// @Param code path string true "referral code"
+// @Success 200 {object} response{data=referralCodeResponse}
+// @Failure 401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/code/{code}/link [get]
+func (h *Handler) getReferralLink(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	code := c.Param("code")
+
+	link, err := h.service.Referral.Link(c.Request.Context(), id, code)
+	if err != nil {
+		newReferralCodeError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, referralCodeResponse{
+		Code: code,
+		Link: link,
+	})
+}
+
+// @Summary Referral Code QR
+// @Security UsersAuth
+// @Tags users-referral
+// @Description get a PNG QR code of the shareable link of an active referral code of the current user
+// @ModuleID getReferralQR
+// @Produce  png
+// @Param code path string true "referral code"
+// @Param size query int false "image width and height in pixels, 64-1024" default(256)
+// @Success 200 {file} binary
+// @Failure 400,401,403,404 {object} response
+// @Failure 500,504 {object} response
+// @Failure default {object} response
+// @Router /users/referral/code/{code}/qr [get]
+func (h *Handler) getReferralQR(c *gin.Context) {
+	id, err := getUserId(c)
+	if err != nil {
+		newResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultReferralQRSize)))
+	if err != nil || size < minReferralQRSize || size > maxReferralQRSize {
+		newResponse(c, http.StatusBadRequest, fmt.Sprintf("size must be between %d and %d", minReferralQRSize, maxReferralQRSize))
+		return
+	}
+
+	png, err := h.service.Referral.QRCode(c.Request.Context(), id, c.Param("code"), size)
+	if err != nil {
+		newReferralCodeError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// newReferralCodeError maps the errors of the endpoints of a single referral code to responses.
+func newReferralCodeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrReferralCodeNotFound):
+		newResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrReferralCodeNotOwned):
+		newResponse(c, http.StatusForbidden, err.Error())
+	default:
+		newInternalError(c, err)
+	}
 }
@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"context"
+	"link-base/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// recordingReferralService records the referral emails it is asked to send.
+type recordingReferralService struct {
+	service.Referral
+
+	userID uuid.UUID
+	to     string
+}
+
+func (s *recordingReferralService) SendEmail(_ context.Context, userID uuid.UUID, to string) error {
+	s.userID = userID
+	s.to = to
+	return nil
+}
+
+func TestHandler_SendEmail(t *testing.T) {
+	referrals := &recordingReferralService{}
+	router, tokenManager := newTestRouter(t, &service.Service{
+		User:     activeTokensUserService{},
+		Referral: referrals,
+	})
+	userID := uuid.New()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/send-email",
+		strings.NewReader(`{"email":"friend@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(authorizationHeader, "Bearer "+newAccessToken(t, tokenManager, userID))
+
+	rec, body := serve(t, router, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d (error %q)", rec.Code, http.StatusAccepted, body.Error)
+	}
+
+	if referrals.userID != userID || referrals.to != "friend@example.com" {
+		t.Errorf("SendEmail(%s, %q), want SendEmail(%s, %q)", referrals.userID, referrals.to, userID, "friend@example.com")
+	}
+}
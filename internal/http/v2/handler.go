@@ -0,0 +1,44 @@
+package v2
+
+import (
+	"link-base/internal/cache"
+	"link-base/internal/config"
+	"link-base/internal/http/shared"
+	"link-base/internal/service"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deps holds the dependencies of the v2 handlers.
+type Deps struct {
+	Service   *service.Service
+	Limiter   cache.RateLimiter
+	Logger    *slog.Logger
+	RateLimit config.RateLimitConfig
+}
+
+// Handler serves the v2 API. It shares the service layer with v1 but has its own
+// request and response types, so the two versions can evolve independently.
+type Handler struct {
+	service   *service.Service
+	limiter   cache.RateLimiter
+	logger    *slog.Logger
+	rateLimit config.RateLimitConfig
+}
+
+func NewHandler(deps Deps) *Handler {
+	return &Handler{
+		service:   deps.Service,
+		limiter:   deps.Limiter,
+		logger:    deps.Logger,
+		rateLimit: deps.RateLimit,
+	}
+}
+
+func (h *Handler) Init(api *gin.RouterGroup) {
+	v2 := api.Group("/v2", shared.Recovery(h.logger, newResponse))
+	{
+		h.initUsersRouter(v2)
+	}
+}
@@ -0,0 +1,22 @@
+package v2
+
+import (
+	"link-base/internal/config"
+	"link-base/internal/http/shared"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitMiddleware returns a middleware that caps the requests of a client IP within
+// a fixed window. The counters are keyed the same way as in v1, so a client shares its
+// budget across API versions.
+//
+// Parameters:
+//   - name: The name of the route group the limit applies to.
+//   - limit: The number of requests allowed per window.
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware, a no-op if rate limiting is disabled.
+func (h *Handler) rateLimitMiddleware(name string, limit config.LimitConfig) gin.HandlerFunc {
+	return shared.RateLimit(h.limiter, h.rateLimit, name, limit, (*gin.Context).ClientIP, newResponse)
+}
@@ -0,0 +1,90 @@
+package v2
+
+import (
+	"link-base/internal/http/shared"
+	"link-base/pkg/requestid"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// response is the envelope shared by every v2 endpoint. Unlike v1 the error is an
+// object with a machine readable code next to the human readable message.
+type response struct {
+	Data      any            `json:"data,omitempty"`
+	Error     *errorResponse `json:"error,omitempty"`
+	RequestId string         `json:"requestId,omitempty"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// errorCodes maps HTTP status codes to the error codes returned to clients.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:      "invalid_request",
+	http.StatusUnauthorized:    "unauthorized",
+	http.StatusForbidden:       "forbidden",
+	http.StatusNotFound:        "not_found",
+	http.StatusConflict:        "conflict",
+	http.StatusTooManyRequests: "rate_limited",
+	http.StatusGatewayTimeout:  "timeout",
+}
+
+// newResponse sends a JSON error response with the given status code and message.
+//
+// This function aborts the current HTTP request and writes the response envelope
+// with an error object whose code is derived from the status code.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - statusCode: The HTTP status code to set in the response.
+//   - message: The message to include in the error object.
+func newResponse(c *gin.Context, statusCode int, message string) {
+	newErrorResponse(c, statusCode, message, nil)
+}
+
+// newInternalError sends the response of an unexpected error: 504 if a database query
+// timed out, 500 otherwise.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - err: The error returned by the service.
+func newInternalError(c *gin.Context, err error) {
+	status, message := shared.InternalError(err)
+	newResponse(c, status, message)
+}
+
+// newErrorResponse sends a JSON error response that also carries details.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - statusCode: The HTTP status code to set in the response.
+//   - message: The message to include in the error object.
+//   - details: Additional details about the error.
+func newErrorResponse(c *gin.Context, statusCode int, message string, details any) {
+	code, ok := errorCodes[statusCode]
+	if !ok {
+		code = "internal_error"
+	}
+
+	c.AbortWithStatusJSON(statusCode, response{
+		Error:     &errorResponse{Code: code, Message: message, Details: details},
+		RequestId: requestid.FromContext(c.Request.Context()),
+	})
+}
+
+// newSuccess sends a JSON success response with the given status code and data.
+//
+// Parameters:
+//   - c: The Gin context for the current HTTP request.
+//   - statusCode: The HTTP status code to set in the response.
+//   - data: The payload to include in the data field.
+func newSuccess(c *gin.Context, statusCode int, data any) {
+	c.JSON(statusCode, response{
+		Data:      data,
+		RequestId: requestid.FromContext(c.Request.Context()),
+	})
+}
@@ -0,0 +1,189 @@
+package v2
+
+import (
+	"errors"
+	"link-base/internal/domain"
+	"link-base/internal/http/shared"
+	"link-base/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenTypeBearer = "Bearer"
+
+type tokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+}
+
+type userSignUpRequest struct {
+	Email        string `json:"email" binding:"required,email,min=2,max=64"`
+	Password     string `json:"password" binding:"required,max=64"`
+	ReferralCode string `json:"referralCode"`
+}
+
+type userSignInRequest struct {
+	Email    string `json:"email" binding:"required,email,min=2,max=64"`
+	Password string `json:"password" binding:"required,max=64"`
+}
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	Challenge         string `json:"challenge"`
+}
+
+type twoFactorSignInRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type passwordPolicyDetails struct {
+	Rules []string `json:"rules"`
+}
+
+func (h *Handler) initUsersRouter(api *gin.RouterGroup) {
+	authLimit := h.rateLimitMiddleware("auth", h.rateLimit.Auth)
+
+	users := api.Group("/users", h.rateLimitMiddleware("default", h.rateLimit.Default))
+	{
+		users.POST("/sign-up", authLimit, h.userSignUp)
+		users.POST("/sign-in", authLimit, h.userSignIn)
+		users.POST("/auth/refresh", h.userRefresh)
+		users.POST("/auth/2fa", authLimit, h.userTwoFactorSignIn)
+	}
+}
+
+// userSignUp creates a user account and returns its first token pair with 201.
+// Password policy violations are reported in the error details.
+func (h *Handler) userSignUp(c *gin.Context) {
+	var inp userSignUpRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if inp.ReferralCode == "" {
+		inp.ReferralCode = c.Query("ref")
+	}
+
+	res, err := h.service.User.SignUp(c.Request.Context(), service.SignUpInput{
+		Email:        inp.Email,
+		Password:     inp.Password,
+		ReferralCode: inp.ReferralCode,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+	})
+	if err != nil {
+		var policyErr *domain.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			newErrorResponse(c, http.StatusBadRequest, policyErr.Error(), passwordPolicyDetails{
+				Rules: policyErr.Rules,
+			})
+			return
+		}
+
+		status, message := shared.SignUpError(err)
+		newResponse(c, status, message)
+		return
+	}
+
+	newSuccess(c, http.StatusCreated, newTokenResponse(res))
+}
+
+// userSignIn signs a user in and returns a token pair, or a two-factor challenge if
+// the user has 2FA enabled.
+func (h *Handler) userSignIn(c *gin.Context) {
+	var inp userSignInRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.service.User.SignIn(c.Request.Context(), service.SignInInput{
+		Email:     inp.Email,
+		Password:  inp.Password,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		status, message := shared.SignInError(err)
+		newResponse(c, status, message)
+		return
+	}
+
+	if res.TwoFactorChallenge != "" {
+		newSuccess(c, http.StatusOK, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			Challenge:         res.TwoFactorChallenge,
+		})
+		return
+	}
+
+	newSuccess(c, http.StatusOK, newTokenResponse(res))
+}
+
+// userTwoFactorSignIn exchanges a two-factor challenge and a TOTP code for a token pair.
+func (h *Handler) userTwoFactorSignIn(c *gin.Context) {
+	var inp twoFactorSignInRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.service.User.CompleteTwoFactor(c.Request.Context(), inp.Challenge, inp.Code, shared.ClientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTokenNotFound), errors.Is(err, domain.ErrInvalidTwoFactorCode):
+			newResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, domain.ErrUserBanned):
+			newResponse(c, http.StatusForbidden, err.Error())
+		default:
+			newInternalError(c, err)
+		}
+
+		return
+	}
+
+	newSuccess(c, http.StatusOK, newTokenResponse(res))
+}
+
+// userRefresh exchanges a refresh token for a new token pair.
+func (h *Handler) userRefresh(c *gin.Context) {
+	var inp refreshRequest
+	if err := c.BindJSON(&inp); err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.service.User.RefreshTokens(c.Request.Context(), inp.RefreshToken, shared.ClientInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrUserBanned) {
+			newResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		newInternalError(c, err)
+		return
+	}
+
+	newSuccess(c, http.StatusOK, newTokenResponse(res))
+}
+
+func newTokenResponse(tokens service.Tokens) tokenResponse {
+	return tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokenTypeBearer,
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "link_base"
+
+var (
+	// HTTPRequests counts the handled HTTP requests by route, method and status.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration observes the latency of HTTP requests by route, method and status.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestsInFlight is the number of HTTP requests currently being handled.
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being handled.",
+	})
+
+	// SignIns counts sign in attempts by result ("success" or "failure").
+	SignIns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sign_ins_total",
+		Help:      "Number of sign in attempts.",
+	}, []string{"result"})
+
+	// DBQueryDuration observes the latency of database queries by operation.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// DBQueryErrors counts the failed database queries by operation.
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Number of failed database queries.",
+	}, []string{"operation"})
+
+	// ReferralCodesCreated counts the created referral codes.
+	ReferralCodesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "referral_codes_created_total",
+		Help:      "Number of created referral codes.",
+	})
+)
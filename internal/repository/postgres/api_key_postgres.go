@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type APIKeyPostgres struct {
+	db queryer
+}
+
+// NewAPIKeyPostgres creates a new instance of APIKeyPostgres.
+//
+// Parameters:
+//   - db: A pointer to a sqlx database connection.
+//   - opts: The timeout and metrics settings of the queries.
+//
+// Returns:
+//   - *APIKeyPostgres: A new instance of APIKeyPostgres.
+func NewAPIKeyPostgres(db *sqlx.DB, opts QueryOptions) *APIKeyPostgres {
+	return &APIKeyPostgres{
+		db: newQueryer(db, opts),
+	}
+}
+
+// Create inserts a new API key into the database.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The API key to be inserted, with the hash of the key, never the key itself.
+//
+// Returns:
+//   - domain.APIKey: The inserted API key with its generated ID and creation time.
+//   - error: An error if the insertion fails.
+func (r *APIKeyPostgres) Create(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	const insertQuery = `
+		INSERT INTO api_key (user_id, name, prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, name, prefix, key_hash, last_used_at, created_at
+	`
+
+	var created domain.APIKey
+	if err := r.db.as("api_key.create").GetContext(ctx, &created, insertQuery, key.UserID, key.Name, key.Prefix, key.KeyHash); err != nil {
+		return domain.APIKey{}, fmt.Errorf("error inserting api key: %w", err)
+	}
+
+	return created, nil
+}
+
+// FindByHash retrieves an API key by the hash of the key.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - keyHash: The hash of the API key.
+//
+// Returns:
+//   - domain.APIKey: The API key if found.
+//   - error: domain.ErrAPIKeyNotFound if no key matches, or an error if the query fails.
+func (r *APIKeyPostgres) FindByHash(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	const findQuery = `
+		SELECT id, user_id, name, prefix, key_hash, last_used_at, created_at
+		FROM api_key
+		WHERE key_hash = $1
+	`
+
+	var key domain.APIKey
+	if err := r.db.as("api_key.find_by_hash").GetContext(ctx, &key, findQuery, keyHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.APIKey{}, domain.ErrAPIKeyNotFound
+		}
+
+		return domain.APIKey{}, fmt.Errorf("error finding api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// FindByUserID retrieves all API keys of the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose API keys are to be retrieved.
+//
+// Returns:
+//   - []domain.APIKey: The API keys, newest first.
+//   - error: An error if there is a database query failure.
+func (r *APIKeyPostgres) FindByUserID(ctx context.Context, userID uuid.UUID) ([]domain.APIKey, error) {
+	const findQuery = `
+		SELECT id, user_id, name, prefix, key_hash, last_used_at, created_at
+		FROM api_key
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	keys := []domain.APIKey{}
+	if err := r.db.as("api_key.find_by_user_id").SelectContext(ctx, &keys, findQuery, userID); err != nil {
+		return nil, fmt.Errorf("error finding api keys for user ID %s: %w", userID, err)
+	}
+
+	return keys, nil
+}
+
+// Delete deletes an API key of the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user owning the API key.
+//   - id: The UUID of the API key to be deleted.
+//
+// Returns:
+//   - error: domain.ErrAPIKeyNotFound if the user has no such key, or an error if the deletion fails.
+func (r *APIKeyPostgres) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	const deleteQuery = `
+		DELETE FROM api_key
+		WHERE id = $1 AND user_id = $2
+	`
+
+	res, err := r.db.as("api_key.delete").ExecContext(ctx, deleteQuery, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+
+	if rows == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// UpdateLastUsed records that the API key has just been used.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - id: The UUID of the API key.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (r *APIKeyPostgres) UpdateLastUsed(ctx context.Context, id uuid.UUID) error {
+	const updateQuery = `
+		UPDATE api_key
+		SET last_used_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.as("api_key.update_last_used").ExecContext(ctx, updateQuery, id)
+	return err
+}
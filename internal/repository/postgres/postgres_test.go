@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"link-base/internal/domain"
+	"link-base/pkg/migrate"
+	"link-base/schema"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// testDB connects to the Postgres database named by TEST_POSTGRES_DSN and migrates it to
+// the latest schema. Tests using it are skipped if the variable isn't set.
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := migrate.Up(context.Background(), db, schema.Migrations); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	return db
+}
+
+// createTestUser inserts a user with a unique email and returns it.
+func createTestUser(t *testing.T, db *sqlx.DB) domain.User {
+	t.Helper()
+
+	user := domain.User{
+		UserId:       uuid.New(),
+		Email:        uuid.NewString() + "@example.com",
+		PasswordHash: "hash",
+		Role:         domain.RoleUser,
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("begin transaction: %v", err)
+	}
+
+	if err := NewUserPostgres(db, db, QueryOptions{}).Create(context.Background(), tx, user); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("create user: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit user: %v", err)
+	}
+
+	return user
+}
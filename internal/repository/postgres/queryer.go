@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"link-base/internal/domain"
+	"link-base/internal/metrics"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// QueryOptions configures how the repositories run their queries.
+type QueryOptions struct {
+	// Timeout bounds every query; a non-positive timeout disables it.
+	Timeout time.Duration
+	// Metrics records the duration and errors of every query by operation.
+	Metrics bool
+}
+
+// queryer runs the queries of a repository with the query timeout, so a stuck query is
+// canceled instead of holding its connection indefinitely, and records their metrics.
+type queryer struct {
+	ext  sqlx.ExtContext
+	opts QueryOptions
+	op   string
+}
+
+// newQueryer creates a queryer on the connection.
+func newQueryer(db *sqlx.DB, opts QueryOptions) queryer {
+	return queryer{
+		ext:  db,
+		opts: opts,
+	}
+}
+
+// withTx returns a queryer running the queries in the transaction with the same options.
+func (q queryer) withTx(tx *sqlx.Tx) queryer {
+	q.ext = tx
+	return q
+}
+
+// as returns a queryer recording the metrics of its queries under the operation name,
+// e.g. "user.find_by_email".
+func (q queryer) as(op string) queryer {
+	q.op = op
+	return q
+}
+
+// GetContext runs the query with the timeout and scans the single result row into dest.
+func (q queryer) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	ctx, cancel := q.context(ctx)
+	defer cancel()
+
+	start := time.Now()
+	err := sqlx.GetContext(ctx, q.ext, dest, query, args...)
+	q.observe(start, err)
+
+	return timeoutErr(ctx, err)
+}
+
+// SelectContext runs the query with the timeout and scans the result rows into dest.
+func (q queryer) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	ctx, cancel := q.context(ctx)
+	defer cancel()
+
+	start := time.Now()
+	err := sqlx.SelectContext(ctx, q.ext, dest, query, args...)
+	q.observe(start, err)
+
+	return timeoutErr(ctx, err)
+}
+
+// ExecContext runs the statement with the timeout.
+func (q queryer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := q.context(ctx)
+	defer cancel()
+
+	start := time.Now()
+	res, err := q.ext.ExecContext(ctx, query, args...)
+	q.observe(start, err)
+
+	return res, timeoutErr(ctx, err)
+}
+
+// context derives the context of a single query, canceled with domain.ErrQueryTimeout as
+// the cause once the timeout expires.
+func (q queryer) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if q.opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, q.opts.Timeout, domain.ErrQueryTimeout)
+}
+
+// observe records the duration of a query started at start and counts it as failed if
+// it returned an error. A missing row is an expected result, not a failure.
+func (q queryer) observe(start time.Time, err error) {
+	if !q.opts.Metrics {
+		return
+	}
+
+	op := q.op
+	if op == "" {
+		op = "unknown"
+	}
+
+	metrics.DBQueryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		metrics.DBQueryErrors.WithLabelValues(op).Inc()
+	}
+}
+
+// timeoutErr marks err as domain.ErrQueryTimeout if the query failed because its timeout
+// expired. The driver reports the cancellation in different ways, so the cause of the
+// query context decides.
+func timeoutErr(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), domain.ErrQueryTimeout) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", domain.ErrQueryTimeout, err)
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"link-base/internal/domain"
 	"time"
@@ -11,20 +13,23 @@ import (
 )
 
 type ReferralPostgres struct {
-	db *sqlx.DB
+	db      queryer
+	replica queryer
 }
 
 // NewReferralPostgres creates a new instance of ReferralPostgres.
 //
 // Parameters:
-//   - db: A pointer to a sqlx database connection.
-//   - logger: A pointer to a slog logger.
+//   - db: A pointer to a sqlx connection to the primary, used for writes.
+//   - replica: A pointer to a sqlx connection to the read replica, used by read-only lookups.
+//   - opts: The timeout and metrics settings of the queries.
 //
 // Returns:
 //   - *ReferralPostgres: A new instance of ReferralPostgres.
-func NewReferralPostgres(db *sqlx.DB) *ReferralPostgres {
+func NewReferralPostgres(db, replica *sqlx.DB, opts QueryOptions) *ReferralPostgres {
 	return &ReferralPostgres{
-		db: db,
+		db:      newQueryer(db, opts),
+		replica: newQueryer(replica, opts),
 	}
 }
 
@@ -32,49 +37,152 @@ func NewReferralPostgres(db *sqlx.DB) *ReferralPostgres {
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
-//   - user: A domain.ReferralUser struct containing the user ID and referral ID.
+//   - user: A domain.ReferralUser struct containing the user ID, referral ID, the code used,
+//     the sign up IP and whether the referral is flagged as suspicious.
 //
 // Returns:
+//   - bool: True if the referral was created, false if the user already has one.
 //   - error: An error if the referral can't be created in the database.
 //
 // Note: The "ON CONFLICT (user_id) DO NOTHING" allows us to ignore the error if the user ID already exists in the
 // table when inserting a new referral. This is useful when a user tries to refer someone who already has an account.
-func (r *ReferralPostgres) CreateReferral(ctx context.Context, tx *sqlx.Tx, user domain.ReferralUser) error {
+func (r *ReferralPostgres) CreateReferral(ctx context.Context, tx *sqlx.Tx, user domain.ReferralUser) (bool, error) {
 	const insertQuery = `
-		INSERT INTO referral (user_id, referred_by_user_id)
-		VALUES ($1, $2)
+		INSERT INTO referral (user_id, referred_by_user_id, code, signup_ip, flagged)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
 		ON CONFLICT (user_id) DO NOTHING
 	`
 
-	_, err := tx.ExecContext(ctx, insertQuery, user.UserID, user.Referral)
-	return err
+	res, err := r.db.withTx(tx).as("referral.create_referral").ExecContext(ctx, insertQuery, user.UserID, user.Referral, user.Code, user.IP, user.Flagged)
+	if err != nil {
+		return false, fmt.Errorf("error creating referral: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error creating referral: %w", err)
+	}
+
+	return rows > 0, nil
 }
 
 // CreateReferralCode creates a new referral code in the database.
 //
+// The code is only inserted if no other active code with the same value exists, so custom
+// aliases can't shadow a code that is still in use, and if the user has fewer than
+// maxActive active codes. The owner's row is locked for the rest of the transaction before
+// the codes are counted, so concurrent calls for the same user can't exceed the limit, and
+// an advisory lock on the code is taken before the insert, so concurrent calls of different
+// users with the same alias can't both create an active code. An expired code of the user
+// with the same value is renewed as a new code.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
 //   - referral: A domain.Referral struct containing the referral code and user ID.
+//   - maxActive: The maximum number of active codes of the user.
 //
 // Returns:
-//   - error: An error if the referral code can't be created in the database.
-func (r *ReferralPostgres) CreateReferralCode(ctx context.Context, referral domain.Referral) error {
+//   - error: domain.ErrReferralCodeLimit if the user already has maxActive active codes,
+//     domain.ErrReferralCodeTaken if an active code with the same value exists, or an
+//     error if the referral code can't be created in the database.
+func (r *ReferralPostgres) CreateReferralCode(ctx context.Context, tx *sqlx.Tx, referral domain.Referral, maxActive int) error {
+	const lockQuery = `
+		SELECT user_id FROM users
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+
+	const countQuery = `
+		SELECT COUNT(*) FROM referral_code
+		WHERE user_id = $1 AND expires_at > NOW()
+	`
+
+	const lockCodeQuery = `
+		SELECT pg_advisory_xact_lock(hashtext($1))
+	`
+
 	const insertQuery = `
-		INSERT INTO referral_code (user_id, code, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO referral_code (user_id, code, expires_at, max_uses)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM referral_code WHERE code = $2 AND expires_at > NOW()
+		)
 		ON CONFLICT (user_id, code) DO UPDATE
-		SET code = $2, expires_at = $3
+		SET expires_at = EXCLUDED.expires_at, max_uses = EXCLUDED.max_uses, uses = 0, created_at = NOW(), updated_at = NOW()
 	`
 
+	db := r.db.withTx(tx)
+
+	var owner uuid.UUID
+	if err := db.as("referral.lock_code_owner").GetContext(ctx, &owner, lockQuery, referral.UserId); err != nil {
+		return fmt.Errorf("error locking owner of referral code: %w", err)
+	}
+
+	var active int
+	if err := db.as("referral.count_active_codes").GetContext(ctx, &active, countQuery, referral.UserId); err != nil {
+		return fmt.Errorf("error counting active referral codes: %w", err)
+	}
+
+	if active >= maxActive {
+		return domain.ErrReferralCodeLimit
+	}
+
+	if _, err := db.as("referral.lock_code").ExecContext(ctx, lockCodeQuery, referral.ReferralCode); err != nil {
+		return fmt.Errorf("error locking referral code: %w", err)
+	}
+
 	ExpiresAt := time.Now().Add(referral.TTL)
-	_, err := r.db.ExecContext(ctx, insertQuery, referral.UserId, referral.ReferralCode, ExpiresAt)
+	res, err := db.as("referral.create_referral_code").ExecContext(ctx, insertQuery, referral.UserId, referral.ReferralCode, ExpiresAt, referral.MaxUses)
+	if err != nil {
+		return fmt.Errorf("error inserting or updating referral: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("error inserting or updating referral: %w", err)
 	}
 
+	if rows == 0 {
+		return domain.ErrReferralCodeTaken
+	}
+
 	return nil
 }
 
+// IncrementUses counts a use of the active referral code unless it has reached its max uses.
+//
+// The check and the increment happen in a single UPDATE, so concurrent sign ups can't
+// use the code more often than allowed.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - code: The referral code that was used.
+//
+// Returns:
+//   - bool: True if the use was counted, false if the code is used up or expired.
+//   - error: An error if the update fails.
+func (r *ReferralPostgres) IncrementUses(ctx context.Context, tx *sqlx.Tx, code string) (bool, error) {
+	const updateQuery = `
+		UPDATE referral_code
+		SET uses = uses + 1, updated_at = NOW()
+		WHERE code = $1 AND expires_at > NOW() AND (max_uses = 0 OR uses < max_uses)
+	`
+
+	res, err := r.db.withTx(tx).as("referral.increment_uses").ExecContext(ctx, updateQuery, code)
+	if err != nil {
+		return false, fmt.Errorf("error incrementing referral code uses: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error incrementing referral code uses: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
 // FindCodeByUserID retrieves all referrals associated with the given user ID from the database.
 //
 // The function executes a SQL query to select the user_id, code, and expires_at
@@ -91,16 +199,16 @@ func (d *ReferralPostgres) FindCodeByUserID(ctx context.Context, id uuid.UUID) (
 	var referrals []domain.Referral
 
 	const findQuery = `
-		SELECT user_id, code
+		SELECT user_id, code, max_uses, uses, created_at, updated_at
 		FROM referral_code
 		WHERE user_id = $1 AND expires_at > NOW()
 	`
 
-	err := d.db.SelectContext(ctx, &referrals, findQuery, id)
+	err := d.replica.as("referral.find_code_by_user_id").SelectContext(ctx, &referrals, findQuery, id)
 	return referrals, err
 }
 
-// FindReferralByUserID retrieves all users that were referred by the given user ID.
+// FindReferralByUserID retrieves a page of users that were referred by the given user ID.
 //
 // The function executes a SQL query to select the user_id
 // column from the referral table where the referred_by_user_id matches the provided UUID.
@@ -108,19 +216,238 @@ func (d *ReferralPostgres) FindCodeByUserID(ctx context.Context, id uuid.UUID) (
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - id: The UUID of the user whose referrals are to be retrieved.
+//   - limit: The maximum number of users to return.
+//   - offset: The number of users to skip.
 //
 // Returns:
-//   - []domain.User: A slice of user details if found.
+//   - []uuid.UUID: A slice of referred user IDs, empty if offset is beyond the end.
 //   - error: An error if there is a database query failure.
-func (d *ReferralPostgres) FindReferralByUserID(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
-	var users []uuid.UUID
+func (d *ReferralPostgres) FindReferralByUserID(ctx context.Context, id uuid.UUID, limit, offset int) ([]uuid.UUID, error) {
+	users := []uuid.UUID{}
 
 	const findQuery = `
 		SELECT user_id
 		FROM referral
 		WHERE referred_by_user_id = $1
+		ORDER BY created_at, user_id
+		LIMIT $2 OFFSET $3
 	`
 
-	err := d.db.SelectContext(ctx, &users, findQuery, id)
+	err := d.replica.as("referral.find_referral_by_user_id").SelectContext(ctx, &users, findQuery, id, limit, offset)
 	return users, err
 }
+
+// CountReferred returns the number of users that were referred by the given user ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - id: The UUID of the referrer.
+//
+// Returns:
+//   - int: The number of referred users.
+//   - error: An error if there is a database query failure.
+func (d *ReferralPostgres) CountReferred(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int
+
+	const countQuery = `
+		SELECT count(*)
+		FROM referral
+		WHERE referred_by_user_id = $1
+	`
+
+	err := d.db.as("referral.count_referred").GetContext(ctx, &count, countQuery, id)
+	return count, err
+}
+
+// CountReferredByCode returns the number of users referred by the given user per referral code.
+//
+// Users who signed up before codes were recorded are counted under an empty code.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - id: The UUID of the referrer.
+//
+// Returns:
+//   - []domain.ReferralCodeCount: The referred sign ups per code, most used first.
+//   - error: An error if there is a database query failure.
+func (d *ReferralPostgres) CountReferredByCode(ctx context.Context, id uuid.UUID) ([]domain.ReferralCodeCount, error) {
+	var counts []domain.ReferralCodeCount
+
+	const countQuery = `
+		SELECT COALESCE(code, '') AS code, count(*) AS count
+		FROM referral
+		WHERE referred_by_user_id = $1
+		GROUP BY code
+		ORDER BY count DESC, code
+	`
+
+	err := d.db.as("referral.count_referred_by_code").SelectContext(ctx, &counts, countQuery, id)
+	return counts, err
+}
+
+// CountReferredByBucket returns the number of users referred by the given user per time bucket.
+//
+// Buckets without sign ups are omitted.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - id: The UUID of the referrer.
+//   - bucket: The date_trunc precision of the buckets, e.g. "day".
+//   - from: The inclusive start of the range.
+//   - to: The exclusive end of the range.
+//
+// Returns:
+//   - []domain.ReferralBucket: The referred sign ups per bucket, ordered by bucket.
+//   - error: An error if there is a database query failure.
+func (d *ReferralPostgres) CountReferredByBucket(ctx context.Context, id uuid.UUID, bucket string, from, to time.Time) ([]domain.ReferralBucket, error) {
+	var buckets []domain.ReferralBucket
+
+	const countQuery = `
+		SELECT date_trunc($2, created_at) AS bucket, count(*) AS count
+		FROM referral
+		WHERE referred_by_user_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	err := d.db.as("referral.count_referred_by_bucket").SelectContext(ctx, &buckets, countQuery, id, bucket, from, to)
+	return buckets, err
+}
+
+// FindCodeOwner retrieves the creator of the active referral code.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - code: The referral code to look up.
+//
+// Returns:
+//   - uuid.UUID: The UUID of the user who created the code.
+//   - error: domain.ErrReferralCodeNotFound if no active code matches, or an error if there
+//     is a database query failure.
+func (d *ReferralPostgres) FindCodeOwner(ctx context.Context, code string) (uuid.UUID, error) {
+	var owner uuid.UUID
+
+	const findQuery = `
+		SELECT user_id
+		FROM referral_code
+		WHERE code = $1 AND expires_at > NOW()
+		LIMIT 1
+	`
+
+	if err := d.db.as("referral.find_code_owner").GetContext(ctx, &owner, findQuery, code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, domain.ErrReferralCodeNotFound
+		}
+
+		return uuid.Nil, fmt.Errorf("error finding referral code owner: %w", err)
+	}
+
+	return owner, nil
+}
+
+// DeleteCode deletes the referral code of the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user who created the code.
+//   - code: The referral code to be deleted.
+//
+// Returns:
+//   - error: An error if the deletion fails.
+func (d *ReferralPostgres) DeleteCode(ctx context.Context, userID uuid.UUID, code string) error {
+	const deleteQuery = `
+		DELETE FROM referral_code
+		WHERE user_id = $1 AND code = $2
+	`
+
+	_, err := d.db.as("referral.delete_code").ExecContext(ctx, deleteQuery, userID, code)
+	return err
+}
+
+// RotateCode replaces an active referral code of the user with a new code in one statement.
+//
+// The new code gets the TTL and max uses the old code was created with, and starts with
+// no uses. The TTL is derived from created_at, which, unlike updated_at, isn't touched
+// when the code is used.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user who created the code.
+//   - oldCode: The referral code to be replaced.
+//   - newCode: The referral code replacing it.
+//
+// Returns:
+//   - domain.Referral: The new referral code with its TTL.
+//   - error: domain.ErrReferralCodeNotFound if the user has no such active code, or an
+//     error if there is a database query failure.
+func (d *ReferralPostgres) RotateCode(ctx context.Context, userID uuid.UUID, oldCode, newCode string) (domain.Referral, error) {
+	var referral domain.Referral
+
+	const rotateQuery = `
+		WITH old AS (
+			DELETE FROM referral_code
+			WHERE user_id = $1 AND code = $2 AND expires_at > NOW()
+			RETURNING user_id, expires_at - created_at AS ttl, max_uses
+		)
+		INSERT INTO referral_code (user_id, code, expires_at, max_uses)
+		SELECT user_id, $3, NOW() + ttl, max_uses FROM old
+		RETURNING user_id, code, max_uses, uses, created_at, updated_at,
+			(EXTRACT(EPOCH FROM expires_at - NOW()) * 1000000000)::BIGINT AS ttl
+	`
+
+	if err := d.db.as("referral.rotate_code").GetContext(ctx, &referral, rotateQuery, userID, oldCode, newCode); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Referral{}, domain.ErrReferralCodeNotFound
+		}
+
+		return domain.Referral{}, fmt.Errorf("error rotating referral code: %w", err)
+	}
+
+	return referral, nil
+}
+
+// DeleteCodesByUserID deletes all referral codes created by the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - id: The UUID of the user whose referral codes are to be deleted.
+//
+// Returns:
+//   - error: An error if the deletion fails.
+func (d *ReferralPostgres) DeleteCodesByUserID(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error {
+	const deleteQuery = `
+		DELETE FROM referral_code
+		WHERE user_id = $1
+	`
+
+	_, err := d.db.withTx(tx).as("referral.delete_codes_by_user_id").ExecContext(ctx, deleteQuery, id)
+	return err
+}
+
+// DeleteExpiredCodes deletes up to limit expired referral codes.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - limit: The maximum number of rows to delete in this batch.
+//
+// Returns:
+//   - int64: The number of deleted rows.
+//   - error: An error if the deletion fails.
+func (d *ReferralPostgres) DeleteExpiredCodes(ctx context.Context, limit int) (int64, error) {
+	const deleteQuery = `
+		DELETE FROM referral_code
+		WHERE ctid IN (
+			SELECT ctid FROM referral_code
+			WHERE expires_at < NOW()
+			LIMIT $1
+		)
+	`
+
+	res, err := d.db.as("referral.delete_expired_codes").ExecContext(ctx, deleteQuery, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired referral codes: %w", err)
+	}
+
+	return res.RowsAffected()
+}
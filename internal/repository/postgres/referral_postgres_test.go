@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// createCode creates the referral code of the user in its own transaction.
+func createCode(ctx context.Context, db *sqlx.DB, repo *ReferralPostgres, userID uuid.UUID, code string, maxActive int) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	referral := domain.Referral{ReferralCode: code, UserId: userID, TTL: time.Hour}
+	if err := repo.CreateReferralCode(ctx, tx, referral, maxActive); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// newCode returns a random referral code.
+func newCode() string {
+	return uuid.NewString()[:12]
+}
+
+func TestReferralPostgres_CreateReferralCode_Limit(t *testing.T) {
+	db := testDB(t)
+	repo := NewReferralPostgres(db, db, QueryOptions{})
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	const maxActive = 3
+	for i := 0; i < maxActive; i++ {
+		if err := createCode(ctx, db, repo, user.UserId, newCode(), maxActive); err != nil {
+			t.Fatalf("code %d of %d: unexpected error: %v", i+1, maxActive, err)
+		}
+	}
+
+	if err := createCode(ctx, db, repo, user.UserId, newCode(), maxActive); !errors.Is(err, domain.ErrReferralCodeLimit) {
+		t.Fatalf("code above the limit: got %v, want %v", err, domain.ErrReferralCodeLimit)
+	}
+}
+
+func TestReferralPostgres_CreateReferralCode_ConcurrentLimit(t *testing.T) {
+	db := testDB(t)
+	repo := NewReferralPostgres(db, db, QueryOptions{})
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	const (
+		maxActive = 2
+		attempts  = 10
+	)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := createCode(ctx, db, repo, user.UserId, newCode(), maxActive)
+			switch {
+			case err == nil:
+				mu.Lock()
+				created++
+				mu.Unlock()
+			case !errors.Is(err, domain.ErrReferralCodeLimit):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != maxActive {
+		t.Fatalf("created %d codes concurrently, want exactly %d", created, maxActive)
+	}
+}
+
+func TestReferralPostgres_CreateReferralCode_ConcurrentAlias(t *testing.T) {
+	db := testDB(t)
+	repo := NewReferralPostgres(db, db, QueryOptions{})
+	ctx := context.Background()
+
+	const users = 5
+	alias := newCode()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < users; i++ {
+		user := createTestUser(t, db)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := createCode(ctx, db, repo, user.UserId, alias, 1)
+			switch {
+			case err == nil:
+				mu.Lock()
+				created++
+				mu.Unlock()
+			case !errors.Is(err, domain.ErrReferralCodeTaken):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatalf("%d users created the same alias concurrently, want exactly 1", created)
+	}
+
+	var active int
+	if err := db.GetContext(ctx, &active, `SELECT COUNT(*) FROM referral_code WHERE code = $1 AND expires_at > NOW()`, alias); err != nil {
+		t.Fatalf("count active codes: %v", err)
+	}
+
+	if active != 1 {
+		t.Fatalf("active rows with the alias = %d, want 1", active)
+	}
+}
+
+func TestReferralPostgres_RotateCode_KeepsTTLAfterUse(t *testing.T) {
+	db := testDB(t)
+	repo := NewReferralPostgres(db, db, QueryOptions{})
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	code := newCode()
+	if err := createCode(ctx, db, repo, user.UserId, code, 1); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+
+	// Pretend the one hour code was created half an hour ago, then use it now.
+	if _, err := db.ExecContext(ctx, `
+		UPDATE referral_code
+		SET created_at = created_at - INTERVAL '30 minutes', expires_at = expires_at - INTERVAL '30 minutes'
+		WHERE code = $1
+	`, code); err != nil {
+		t.Fatalf("backdate code: %v", err)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin transaction: %v", err)
+	}
+
+	if counted, err := repo.IncrementUses(ctx, tx, code); err != nil || !counted {
+		_ = tx.Rollback()
+		t.Fatalf("IncrementUses() = %v, %v; want true, nil", counted, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit use: %v", err)
+	}
+
+	rotated, err := repo.RotateCode(ctx, user.UserId, code, newCode())
+	if err != nil {
+		t.Fatalf("RotateCode() error = %v", err)
+	}
+
+	if rotated.TTL < 59*time.Minute || rotated.TTL > time.Hour {
+		t.Fatalf("rotated code TTL = %s, want the original 1h", rotated.TTL)
+	}
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"link-base/internal/domain"
 
@@ -10,20 +12,20 @@ import (
 )
 
 type RefreshTokenPostgres struct {
-	db *sqlx.DB
+	db queryer
 }
 
 // NewRefreshTokenPostgres creates a new instance of RefreshTokenPostgres.
 //
 // Parameters:
 //   - db: A pointer to a sqlx database connection.
-//   - logger: A pointer to a slog logger.
+//   - opts: The timeout and metrics settings of the queries.
 //
 // Returns:
 //   - *RefreshTokenPostgres: A new instance of RefreshTokenPostgres.
-func NewRefreshTokenPostgres(db *sqlx.DB) *RefreshTokenPostgres {
+func NewRefreshTokenPostgres(db *sqlx.DB, opts QueryOptions) *RefreshTokenPostgres {
 	return &RefreshTokenPostgres{
-		db: db,
+		db: newQueryer(db, opts),
 	}
 }
 
@@ -31,19 +33,20 @@ func NewRefreshTokenPostgres(db *sqlx.DB) *RefreshTokenPostgres {
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
-//   - refreshToken: The refresh token to be inserted, including user ID and expiration.
+//   - refreshToken: The refresh token to be inserted, including user ID, client and expiration.
 //
 // Returns:
 //   - error: An error if the insertion or update fails.
 func (r *RefreshTokenPostgres) Create(ctx context.Context, refreshToken domain.RefreshToken) error {
 	const insertQuery = `
-		INSERT INTO refresh_token (user_id, refresh_token, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO refresh_token (user_id, refresh_token, user_agent, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (user_id, refresh_token) DO UPDATE
-		SET refresh_token = $2, expires_at = $3
+		SET refresh_token = $2, user_agent = $3, ip = $4, expires_at = $5, updated_at = NOW()
 	`
 
-	_, err := r.db.ExecContext(ctx, insertQuery, refreshToken.UserID, refreshToken.RefreshToken, refreshToken.ExpiresAt)
+	_, err := r.db.as("refresh_token.create").ExecContext(ctx, insertQuery, refreshToken.UserID, refreshToken.RefreshToken,
+		refreshToken.UserAgent, refreshToken.IP, refreshToken.ExpiresAt)
 	if err != nil {
 		return fmt.Errorf("error inserting or updating refresh token: %w", err)
 	}
@@ -65,10 +68,149 @@ func (r *RefreshTokenPostgres) DeleteByUserID(ctx context.Context, userID uuid.U
 		WHERE user_id = $1
 	`
 
-	_, err := r.db.ExecContext(ctx, deleteQuery, userID)
+	_, err := r.db.as("refresh_token.delete_by_user_id").ExecContext(ctx, deleteQuery, userID)
 	return err
 }
 
+// DeleteByRefreshToken deletes the given refresh token, ending a single session.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - refreshToken: The refresh token to be deleted.
+//
+// Returns:
+//   - error: domain.ErrSessionNotFound if no refresh token matched, or an error if the deletion fails.
+func (r *RefreshTokenPostgres) DeleteByRefreshToken(ctx context.Context, refreshToken string) error {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE refresh_token = $1
+	`
+
+	res, err := r.db.as("refresh_token.delete_by_refresh_token").ExecContext(ctx, deleteQuery, refreshToken)
+	if err != nil {
+		return fmt.Errorf("error deleting refresh token: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting refresh token: %w", err)
+	}
+
+	if rows == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// Consume deletes the given active refresh token and returns the user it belonged to,
+// ending a single session.
+//
+// The lookup and the deletion are one statement, so of several concurrent calls with the
+// same refresh token only one succeeds.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - refreshToken: The refresh token to be consumed.
+//
+// Returns:
+//   - uuid.UUID: The UUID of the user the refresh token belonged to.
+//   - error: domain.ErrSessionNotFound if no active refresh token matched, or an error if the
+//     deletion fails.
+func (r *RefreshTokenPostgres) Consume(ctx context.Context, refreshToken string) (uuid.UUID, error) {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE refresh_token = $1 AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	if err := r.db.as("refresh_token.consume").GetContext(ctx, &userID, deleteQuery, refreshToken); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, domain.ErrSessionNotFound
+		}
+
+		return uuid.Nil, fmt.Errorf("error deleting refresh token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// DeleteByUserIDTx deletes all refresh tokens associated with the given user ID within a transaction.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - userID: The UUID of the user whose refresh tokens are to be deleted.
+//
+// Returns:
+//   - error: An error if the deletion fails.
+func (r *RefreshTokenPostgres) DeleteByUserIDTx(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) error {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE user_id = $1
+	`
+
+	_, err := r.db.withTx(tx).as("refresh_token.delete_by_user_id_tx").ExecContext(ctx, deleteQuery, userID)
+	return err
+}
+
+// DeleteOthersByUserID deletes all refresh tokens of the given user except the provided one.
+//
+// Nothing is deleted unless the provided refresh token belongs to the user, so a foreign or
+// unknown token never wipes the user's sessions.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose refresh tokens are to be deleted.
+//   - refreshToken: The refresh token of the current session to be kept.
+//
+// Returns:
+//   - error: An error if the deletion fails.
+func (r *RefreshTokenPostgres) DeleteOthersByUserID(ctx context.Context, userID uuid.UUID, refreshToken string) error {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE user_id = $1 AND refresh_token <> $2
+		AND EXISTS (
+			SELECT 1 FROM refresh_token
+			WHERE user_id = $1 AND refresh_token = $2
+		)
+	`
+
+	_, err := r.db.as("refresh_token.delete_others_by_user_id").ExecContext(ctx, deleteQuery, userID, refreshToken)
+	return err
+}
+
+// DeleteOldestByUserID deletes the n oldest active refresh tokens of the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose refresh tokens are to be deleted.
+//   - n: The number of refresh tokens to delete.
+//
+// Returns:
+//   - []string: The deleted refresh tokens.
+//   - error: An error if the deletion fails.
+func (r *RefreshTokenPostgres) DeleteOldestByUserID(ctx context.Context, userID uuid.UUID, n int) ([]string, error) {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE session_id IN (
+			SELECT session_id FROM refresh_token
+			WHERE user_id = $1 AND expires_at > NOW()
+			ORDER BY created_at
+			LIMIT $2
+		)
+		RETURNING refresh_token
+	`
+
+	deleted := []string{}
+	if err := r.db.as("refresh_token.delete_oldest_by_user_id").SelectContext(ctx, &deleted, deleteQuery, userID, n); err != nil {
+		return nil, fmt.Errorf("error deleting oldest refresh tokens for user ID %s: %w", userID, err)
+	}
+
+	return deleted, nil
+}
+
 // FindByUserID retrieves a refresh token from the database by the user's unique user ID.
 //
 // Parameters:
@@ -80,19 +222,44 @@ func (r *RefreshTokenPostgres) DeleteByUserID(ctx context.Context, userID uuid.U
 //   - error: An error if the refresh token is not found or if there is a database query failure.
 func (r *RefreshTokenPostgres) FindByUserID(ctx context.Context, userID uuid.UUID) (domain.RefreshToken, error) {
 	const findQuery = `
-		SELECT user_id, refresh_token, expires_at
+		SELECT session_id, user_id, refresh_token, user_agent, ip, expires_at, created_at, updated_at
 		FROM refresh_token
 		WHERE user_id = $1 AND expires_at > NOW()
 	`
 
 	var refreshToken domain.RefreshToken
-	if err := r.db.GetContext(ctx, &refreshToken, findQuery, userID); err != nil {
+	if err := r.db.as("refresh_token.find_by_user_id").GetContext(ctx, &refreshToken, findQuery, userID); err != nil {
 		return domain.RefreshToken{}, fmt.Errorf("refresh token not found for user ID %s: %w", userID, err)
 	}
 
 	return refreshToken, nil
 }
 
+// FindActiveByUserID retrieves all non-expired refresh tokens of the given user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose refresh tokens are to be retrieved.
+//
+// Returns:
+//   - []domain.RefreshToken: The active refresh tokens, soonest expiring first.
+//   - error: An error if there is a database query failure.
+func (r *RefreshTokenPostgres) FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]domain.RefreshToken, error) {
+	const findQuery = `
+		SELECT session_id, user_id, refresh_token, user_agent, ip, expires_at, created_at, updated_at
+		FROM refresh_token
+		WHERE user_id = $1 AND expires_at > NOW()
+		ORDER BY expires_at
+	`
+
+	refreshTokens := []domain.RefreshToken{}
+	if err := r.db.as("refresh_token.find_active_by_user_id").SelectContext(ctx, &refreshTokens, findQuery, userID); err != nil {
+		return nil, fmt.Errorf("error finding refresh tokens for user ID %s: %w", userID, err)
+	}
+
+	return refreshTokens, nil
+}
+
 // FindByRefreshToken retrieves a refresh token from the database by the refresh token itself.
 //
 // Parameters:
@@ -101,21 +268,52 @@ func (r *RefreshTokenPostgres) FindByUserID(ctx context.Context, userID uuid.UUI
 //
 // Returns:
 //   - domain.RefreshToken: The refresh token details if found.
-//   - error: An error if the refresh token is not found or if there is a database query failure.
+//   - error: domain.ErrSessionNotFound if the refresh token is unknown or expired, or an error
+//     if there is a database query failure.
 func (r *RefreshTokenPostgres) FindByRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error) {
 	const findQuery = `
-		SELECT user_id, refresh_token, expires_at
+		SELECT session_id, user_id, refresh_token, user_agent, ip, expires_at, created_at, updated_at
 		FROM refresh_token
 		WHERE refresh_token = $1 AND expires_at > NOW()
 		LIMIT 1
 	`
 
 	var refreshTokenFromDB domain.RefreshToken
-	err := r.db.GetContext(ctx, &refreshTokenFromDB, findQuery, refreshToken)
+	err := r.db.as("refresh_token.find_by_refresh_token").GetContext(ctx, &refreshTokenFromDB, findQuery, refreshToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.RefreshToken{}, domain.ErrSessionNotFound
+	}
 
 	if err != nil {
-		return domain.RefreshToken{}, fmt.Errorf("refresh token not found: %w", err)
+		return domain.RefreshToken{}, fmt.Errorf("error finding refresh token: %w", err)
 	}
 
 	return refreshTokenFromDB, nil
 }
+
+// DeleteExpired deletes up to limit expired refresh tokens.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - limit: The maximum number of rows to delete in this batch.
+//
+// Returns:
+//   - int64: The number of deleted rows.
+//   - error: An error if the deletion fails.
+func (r *RefreshTokenPostgres) DeleteExpired(ctx context.Context, limit int) (int64, error) {
+	const deleteQuery = `
+		DELETE FROM refresh_token
+		WHERE ctid IN (
+			SELECT ctid FROM refresh_token
+			WHERE expires_at < NOW()
+			LIMIT $1
+		)
+	`
+
+	res, err := r.db.as("refresh_token.delete_expired").ExecContext(ctx, deleteQuery, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired refresh tokens: %w", err)
+	}
+
+	return res.RowsAffected()
+}
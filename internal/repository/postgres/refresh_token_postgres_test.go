@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRefreshTokenPostgres_DeleteByRefreshToken(t *testing.T) {
+	db := testDB(t)
+	repo := NewRefreshTokenPostgres(db, QueryOptions{})
+	ctx := context.Background()
+	user := createTestUser(t, db)
+
+	refreshToken := uuid.NewString()
+	if err := repo.Create(ctx, domain.RefreshToken{
+		UserID:       user.UserId,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := repo.DeleteByRefreshToken(ctx, refreshToken); err != nil {
+		t.Fatalf("DeleteByRefreshToken() error = %v", err)
+	}
+
+	if err := repo.DeleteByRefreshToken(ctx, refreshToken); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Fatalf("DeleteByRefreshToken() of a deleted token error = %v, want %v", err, domain.ErrSessionNotFound)
+	}
+}
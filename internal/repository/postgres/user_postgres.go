@@ -2,28 +2,38 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"link-base/internal/domain"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// uniqueViolation is the SQLSTATE Postgres reports for a unique constraint violation.
+const uniqueViolation = "23505"
+
 type UserPostgres struct {
-	db *sqlx.DB
+	db      queryer
+	replica queryer
 }
 
 // NewUserPostgres creates a new instance of UserPostgres.
 //
 // Parameters:
-//   - db: A pointer to a sqlx database connection.
-//   - logger: A pointer to a slog logger.
+//   - db: A pointer to a sqlx connection to the primary, used for writes.
+//   - replica: A pointer to a sqlx connection to the read replica, used by read-only lookups.
+//   - opts: The timeout and metrics settings of the queries.
 //
 // Returns:
 //   - *UserPostgres: A new instance of UserPostgres.
-func NewUserPostgres(db *sqlx.DB) *UserPostgres {
+func NewUserPostgres(db, replica *sqlx.DB, opts QueryOptions) *UserPostgres {
 	return &UserPostgres{
-		db: db,
+		db:      newQueryer(db, opts),
+		replica: newQueryer(replica, opts),
 	}
 }
 
@@ -32,56 +42,95 @@ func NewUserPostgres(db *sqlx.DB) *UserPostgres {
 // The method executes a SQL query to insert a new user into the users table.
 // The context is used to pass request-scoped values to the database driver.
 //
-// The method returns an error if the user already exists in the database.
+// The insert is skipped if an active user with the same email already exists, which is
+// detected atomically by the database, so concurrent sign ups can't both succeed.
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - tx: A pointer to a sqlx transaction.
-//   - u: The user to be created, containing the user ID, email, and password hash.
+//   - u: The user to be created, containing the user ID, email, password hash, salt and role.
 //
 // Returns:
-//   - error: An error if the user already exists in the database.
+//   - error: domain.ErrEmailInUse if no row was inserted because the email is taken, or an
+//     error if the insert fails.
 func (d *UserPostgres) Create(ctx context.Context, tx *sqlx.Tx, u domain.User) error {
 	const queryCreate = `
-		INSERT INTO users (user_id, email, password_hash)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (email) DO NOTHING
+		INSERT INTO users (user_id, email, password_hash, salt, role)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT ((lower(email))) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING user_id
 	`
-	_, err := tx.ExecContext(ctx, queryCreate, u.UserId, u.Email, u.PasswordHash)
-	return err
+
+	var id uuid.UUID
+	if err := d.db.withTx(tx).as("user.create").GetContext(ctx, &id, queryCreate, u.UserId, u.Email, u.PasswordHash, u.Salt, u.Role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrEmailInUse
+		}
+
+		return fmt.Errorf("could not create user: %w", err)
+	}
+
+	return nil
 }
 
-// FindByUserId retrieves a user from the database by their unique user ID.
+// FindByUserId retrieves an active user from the database by their unique user ID.
 //
-// The function executes a SQL query to select the user_id, email, and password_hash
+// The function executes a SQL query to select the user_id, email, password_hash, salt, role, is_verified,
+// totp_secret, totp_enabled, is_banned, points and last_login_at
 // columns from the users table where the user_id matches the provided UUID.
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - userId: The UUID of the user to be retrieved.
 //
+// The lookup runs on the read replica, so a user written moments ago may not be visible yet;
+// paths reading their own writes use FindByUserIdPrimary.
+//
 // Returns:
 //   - domain.User: The user details if found.
 //   - error: An error if the user is not found or if there is a database query failure.
 func (d *UserPostgres) FindByUserId(ctx context.Context, userId uuid.UUID) (domain.User, error) {
+	return d.findByUserId(ctx, d.replica.as("user.find_by_user_id"), userId)
+}
+
+// FindByUserIdPrimary retrieves an active user by their unique user ID from the primary.
+//
+// It is used right after writes, e.g. when the session of a new user is created, where the
+// read replica may still lag behind.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user to be retrieved.
+//
+// Returns:
+//   - domain.User: The user details if found.
+//   - error: An error if the user is not found or if there is a database query failure.
+func (d *UserPostgres) FindByUserIdPrimary(ctx context.Context, userId uuid.UUID) (domain.User, error) {
+	return d.findByUserId(ctx, d.db.as("user.find_by_user_id_primary"), userId)
+}
+
+// findByUserId runs the lookup of an active user by ID on the given connection.
+func (d *UserPostgres) findByUserId(ctx context.Context, q queryer, userId uuid.UUID) (domain.User, error) {
 	var usr domain.User
 	const findQuery = `
-		SELECT user_id, email, password_hash
+		SELECT user_id, email, password_hash, salt, role, is_verified, COALESCE(totp_secret, '') AS totp_secret,
+			totp_enabled, is_banned, points, last_login_at, created_at, updated_at
 		FROM users
-		WHERE user_id = $1
+		WHERE user_id = $1 AND deleted_at IS NULL
 		LIMIT 1
 	`
 
-	if err := d.db.GetContext(ctx, &usr, findQuery, userId); err != nil {
+	if err := q.GetContext(ctx, &usr, findQuery, userId); err != nil {
 		return usr, fmt.Errorf("could not find user with ID %s: %w", userId, err)
 	}
 
 	return usr, nil
 }
 
-// FindByEmail retrieves a user from the database by their unique email address.
+// FindByEmail retrieves an active user from the database by their unique email address.
 //
-// The function executes a SQL query to select the user_id, email, and password_hash
+// The function executes a SQL query to select the user_id, email, password_hash, salt, role, is_verified,
+// totp_secret, totp_enabled, is_banned, points and last_login_at
 // columns from the users table where the email matches the provided string.
 //
 // Parameters:
@@ -90,17 +139,45 @@ func (d *UserPostgres) FindByUserId(ctx context.Context, userId uuid.UUID) (doma
 //
 // Returns:
 //   - domain.User: The user details if found.
-//   - error: An error if the user is not found or if there is a database query failure.
+//   - error: domain.ErrUserNotFound if there's no active user with the email, or an error if
+//     there is a database query failure.
 func (d *UserPostgres) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return d.findByEmail(ctx, d.replica.as("user.find_by_email"), email)
+}
+
+// FindByEmailPrimary retrieves an active user by their email address from the primary.
+//
+// It is used where a stale answer from the read replica would be wrong, e.g. when checking
+// that an email is still free right before it is assigned.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The email address of the user to be retrieved.
+//
+// Returns:
+//   - domain.User: The user details if found.
+//   - error: domain.ErrUserNotFound if there's no active user with the email, or an error if
+//     there is a database query failure.
+func (d *UserPostgres) FindByEmailPrimary(ctx context.Context, email string) (domain.User, error) {
+	return d.findByEmail(ctx, d.db.as("user.find_by_email_primary"), email)
+}
+
+// findByEmail runs the lookup of an active user by email on the given connection.
+func (d *UserPostgres) findByEmail(ctx context.Context, q queryer, email string) (domain.User, error) {
 	const findQuery = `
-		SELECT user_id, email, password_hash
+		SELECT user_id, email, password_hash, salt, role, is_verified, COALESCE(totp_secret, '') AS totp_secret,
+			totp_enabled, is_banned, points, last_login_at, created_at, updated_at
 		FROM users
-		WHERE email = $1
+		WHERE lower(email) = lower($1) AND deleted_at IS NULL
 		LIMIT 1
 	`
 
 	var user domain.User
-	if err := d.db.GetContext(ctx, &user, findQuery, email); err != nil {
+	if err := q.GetContext(ctx, &user, findQuery, email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, fmt.Errorf("%w: %w", domain.ErrUserNotFound, err)
+		}
+
 		return domain.User{
 			UserId: uuid.Nil,
 		}, fmt.Errorf("user not found: %w", err)
@@ -108,3 +185,332 @@ func (d *UserPostgres) FindByEmail(ctx context.Context, email string) (domain.Us
 
 	return user, nil
 }
+
+// UpdatePasswordHash replaces the password hash of the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user whose password hash is to be updated.
+//   - passwordHash: The new password hash.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) UpdatePasswordHash(ctx context.Context, userId uuid.UUID, passwordHash string) error {
+	const updateQuery = `
+		UPDATE users
+		SET password_hash = $2, updated_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.update_password_hash").ExecContext(ctx, updateQuery, userId, passwordHash); err != nil {
+		return fmt.Errorf("could not update password hash for user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// SetVerified marks the email of the user with the given ID as verified.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user to be marked as verified.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) SetVerified(ctx context.Context, userId uuid.UUID) error {
+	const updateQuery = `
+		UPDATE users
+		SET is_verified = TRUE, updated_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.set_verified").ExecContext(ctx, updateQuery, userId); err != nil {
+		return fmt.Errorf("could not verify user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// UpdateEmail replaces the email of the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user whose email is to be updated.
+//   - email: The new email address.
+//
+// Returns:
+//   - error: domain.ErrEmailInUse if another active user has the email, or an error if the
+//     update fails.
+func (d *UserPostgres) UpdateEmail(ctx context.Context, userId uuid.UUID, email string) error {
+	const updateQuery = `
+		UPDATE users
+		SET email = $2, updated_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.update_email").ExecContext(ctx, updateQuery, userId, email); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return domain.ErrEmailInUse
+		}
+
+		return fmt.Errorf("could not update email for user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// Deactivate soft-deletes the user with the given ID by stamping deleted_at.
+//
+// Deactivated users are ignored by FindByEmail and FindByUserId, so their email can be reused.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - userId: The UUID of the user to be deactivated.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) Deactivate(ctx context.Context, tx *sqlx.Tx, userId uuid.UUID) error {
+	const updateQuery = `
+		UPDATE users
+		SET deleted_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	if _, err := d.db.withTx(tx).as("user.deactivate").ExecContext(ctx, updateQuery, userId); err != nil {
+		return fmt.Errorf("could not deactivate user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// UpdateLastLogin sets the last login time of the user with the given ID to now.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user who signed in.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) UpdateLastLogin(ctx context.Context, userId uuid.UUID) error {
+	const updateQuery = `
+		UPDATE users
+		SET last_login_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.update_last_login").ExecContext(ctx, updateQuery, userId); err != nil {
+		return fmt.Errorf("could not update last login for user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// SetTOTPSecret stores a pending, not yet enabled TOTP secret for the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user enrolling in two-factor authentication.
+//   - secret: The encrypted TOTP secret.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) SetTOTPSecret(ctx context.Context, userId uuid.UUID, secret string) error {
+	const updateQuery = `
+		UPDATE users
+		SET totp_secret = $2, totp_enabled = FALSE, updated_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.set_totp_secret").ExecContext(ctx, updateQuery, userId, secret); err != nil {
+		return fmt.Errorf("could not set totp secret for user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// SetTOTPEnabled enables or disables two-factor authentication for the user with the given ID.
+//
+// Disabling also clears the stored secret, so enabling again requires a new enrollment.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userId: The UUID of the user.
+//   - enabled: Whether two-factor authentication is enabled.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (d *UserPostgres) SetTOTPEnabled(ctx context.Context, userId uuid.UUID, enabled bool) error {
+	const updateQuery = `
+		UPDATE users
+		SET totp_enabled = $2,
+			totp_secret = CASE WHEN $2 THEN totp_secret ELSE NULL END,
+			updated_at = NOW()
+		WHERE user_id = $1
+	`
+
+	if _, err := d.db.as("user.set_totp_enabled").ExecContext(ctx, updateQuery, userId, enabled); err != nil {
+		return fmt.Errorf("could not update totp for user with ID %s: %w", userId, err)
+	}
+
+	return nil
+}
+
+// List retrieves a page of active users, newest first.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - limit: The maximum number of users to return.
+//   - offset: The number of users to skip.
+//
+// Returns:
+//   - []domain.User: A page of users, empty if offset is beyond the end.
+//   - error: An error if there is a database query failure.
+func (d *UserPostgres) List(ctx context.Context, limit, offset int) ([]domain.User, error) {
+	const listQuery = `
+		SELECT user_id, email, password_hash, salt, role, is_verified, COALESCE(totp_secret, '') AS totp_secret,
+			totp_enabled, is_banned, points, last_login_at, created_at, updated_at
+		FROM users
+		WHERE deleted_at IS NULL
+		ORDER BY created_at DESC, user_id
+		LIMIT $1 OFFSET $2
+	`
+
+	users := []domain.User{}
+	if err := d.db.as("user.list").SelectContext(ctx, &users, listQuery, limit, offset); err != nil {
+		return nil, fmt.Errorf("could not list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Count returns the number of active users.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//
+// Returns:
+//   - int: The number of active users.
+//   - error: An error if there is a database query failure.
+func (d *UserPostgres) Count(ctx context.Context) (int, error) {
+	const countQuery = `
+		SELECT count(*)
+		FROM users
+		WHERE deleted_at IS NULL
+	`
+
+	var count int
+	if err := d.db.as("user.count").GetContext(ctx, &count, countQuery); err != nil {
+		return 0, fmt.Errorf("could not count users: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetBanned bans or unbans the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - userId: The UUID of the user.
+//   - banned: Whether the user is banned.
+//
+// Returns:
+//   - error: domain.ErrUserNotFound if there's no active user with the ID, or an error if the update fails.
+func (d *UserPostgres) SetBanned(ctx context.Context, tx *sqlx.Tx, userId uuid.UUID, banned bool) error {
+	const updateQuery = `
+		UPDATE users
+		SET is_banned = $2, updated_at = NOW()
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	res, err := d.db.withTx(tx).as("user.set_banned").ExecContext(ctx, updateQuery, userId, banned)
+	if err != nil {
+		return fmt.Errorf("could not update ban for user with ID %s: %w", userId, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SearchByEmailPrefix retrieves the active users whose email starts with the given prefix,
+// ignoring case.
+//
+// LIKE wildcards in the prefix are escaped, so the prefix always matches literally.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - prefix: The beginning of the email address.
+//   - limit: The maximum number of users to return.
+//
+// Returns:
+//   - []domain.User: The matching users, ordered by email.
+//   - error: An error if there is a database query failure.
+func (d *UserPostgres) SearchByEmailPrefix(ctx context.Context, prefix string, limit int) ([]domain.User, error) {
+	const searchQuery = `
+		SELECT user_id, email, password_hash, salt, role, is_verified, COALESCE(totp_secret, '') AS totp_secret,
+			totp_enabled, is_banned, points, last_login_at, created_at, updated_at
+		FROM users
+		WHERE email ILIKE $1 ESCAPE '\' AND deleted_at IS NULL
+		ORDER BY email
+		LIMIT $2
+	`
+
+	users := []domain.User{}
+	if err := d.db.as("user.search_by_email_prefix").SelectContext(ctx, &users, searchQuery, escapeLike(prefix)+"%", limit); err != nil {
+		return nil, fmt.Errorf("could not search users: %w", err)
+	}
+
+	return users, nil
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes s for use as a literal in a LIKE pattern with '\' as the escape character.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// AddPoints credits points to the balance of the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - tx: A pointer to a sqlx transaction.
+//   - userId: The UUID of the user to credit.
+//   - points: The number of points to add.
+//
+// Returns:
+//   - error: domain.ErrUserNotFound if there's no active user with the ID, or an error if the update fails.
+func (d *UserPostgres) AddPoints(ctx context.Context, tx *sqlx.Tx, userId uuid.UUID, points int) error {
+	const updateQuery = `
+		UPDATE users
+		SET points = points + $2, updated_at = NOW()
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	res, err := d.db.withTx(tx).as("user.add_points").ExecContext(ctx, updateQuery, userId, points)
+	if err != nil {
+		return fmt.Errorf("could not add points for user with ID %s: %w", userId, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
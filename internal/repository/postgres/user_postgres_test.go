@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/domain"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUserPostgres_Create_ConcurrentSignUps(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserPostgres(db, db, QueryOptions{})
+	ctx := context.Background()
+
+	email := uuid.NewString() + "@example.com"
+	emails := []string{email, strings.ToUpper(email)}
+
+	// Both sign ups open their transaction before either inserts, so neither can see the
+	// other's row; the unique index has to decide.
+	var (
+		begun sync.WaitGroup
+		done  sync.WaitGroup
+		errs  = make([]error, len(emails))
+	)
+	begun.Add(len(emails))
+	for i, e := range emails {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+
+			tx, err := db.BeginTxx(ctx, nil)
+			begun.Done()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			begun.Wait()
+
+			user := domain.User{UserId: uuid.New(), Email: e, PasswordHash: "hash", Role: domain.RoleUser}
+			if errs[i] = repo.Create(ctx, tx, user); errs[i] != nil {
+				_ = tx.Rollback()
+				return
+			}
+			errs[i] = tx.Commit()
+		}()
+	}
+	done.Wait()
+
+	var created, inUse int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, domain.ErrEmailInUse):
+			inUse++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if created != 1 || inUse != 1 {
+		t.Fatalf("got %d created and %d rejected sign ups, want 1 and 1", created, inUse)
+	}
+}
+
+func TestUserPostgres_UpdateLastLogin(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserPostgres(db, db, QueryOptions{})
+	ctx := context.Background()
+	user := createTestUser(t, db)
+
+	var previous time.Time
+	for i := range 2 {
+		if err := repo.UpdateLastLogin(ctx, user.UserId); err != nil {
+			t.Fatalf("UpdateLastLogin() error = %v", err)
+		}
+
+		found, err := repo.FindByUserIdPrimary(ctx, user.UserId)
+		if err != nil {
+			t.Fatalf("FindByUserIdPrimary() error = %v", err)
+		}
+
+		if found.LastLoginAt == nil {
+			t.Fatalf("login %d: last_login_at is not set", i+1)
+		}
+
+		if !found.LastLoginAt.After(previous) {
+			t.Fatalf("login %d: last_login_at = %v, want after %v", i+1, *found.LastLoginAt, previous)
+		}
+		previous = *found.LastLoginAt
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
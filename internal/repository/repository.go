@@ -4,6 +4,7 @@ import (
 	"context"
 	"link-base/internal/domain"
 	"link-base/internal/repository/postgres"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -13,33 +14,83 @@ import (
 type User interface {
 	Create(ctx context.Context, tx *sqlx.Tx, user domain.User) error
 	FindByUserId(ctx context.Context, id uuid.UUID) (domain.User, error)
+	FindByUserIdPrimary(ctx context.Context, id uuid.UUID) (domain.User, error)
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
+	FindByEmailPrimary(ctx context.Context, email string) (domain.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	SetVerified(ctx context.Context, id uuid.UUID) error
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+	Deactivate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error
+	UpdateLastLogin(ctx context.Context, id uuid.UUID) error
+	SetTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error
+	SetTOTPEnabled(ctx context.Context, id uuid.UUID, enabled bool) error
+	List(ctx context.Context, limit, offset int) ([]domain.User, error)
+	Count(ctx context.Context) (int, error)
+	SetBanned(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, banned bool) error
+	SearchByEmailPrefix(ctx context.Context, prefix string, limit int) ([]domain.User, error)
+	AddPoints(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, points int) error
 }
 
 type RefreshToken interface {
 	Create(ctx context.Context, session domain.RefreshToken) error
 	DeleteByUserID(ctx context.Context, userID uuid.UUID) error
+	DeleteByRefreshToken(ctx context.Context, refreshToken string) error
+	Consume(ctx context.Context, refreshToken string) (uuid.UUID, error)
+	DeleteOthersByUserID(ctx context.Context, userID uuid.UUID, refreshToken string) error
+	DeleteByUserIDTx(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) error
+	DeleteOldestByUserID(ctx context.Context, userID uuid.UUID, n int) ([]string, error)
 	FindByUserID(ctx context.Context, userID uuid.UUID) (domain.RefreshToken, error)
+	FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]domain.RefreshToken, error)
 	FindByRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error)
+	DeleteExpired(ctx context.Context, limit int) (int64, error)
 }
 
 type Referral interface {
-	CreateReferral(ctx context.Context, tx *sqlx.Tx, user domain.ReferralUser) error
-	FindReferralByUserID(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
-	CreateReferralCode(ctx context.Context, referral domain.Referral) error
+	CreateReferral(ctx context.Context, tx *sqlx.Tx, user domain.ReferralUser) (bool, error)
+	FindReferralByUserID(ctx context.Context, id uuid.UUID, limit, offset int) ([]uuid.UUID, error)
+	CountReferred(ctx context.Context, id uuid.UUID) (int, error)
+	CountReferredByCode(ctx context.Context, id uuid.UUID) ([]domain.ReferralCodeCount, error)
+	CountReferredByBucket(ctx context.Context, id uuid.UUID, bucket string, from, to time.Time) ([]domain.ReferralBucket, error)
+	CreateReferralCode(ctx context.Context, tx *sqlx.Tx, referral domain.Referral, maxActive int) error
 	FindCodeByUserID(ctx context.Context, id uuid.UUID) ([]domain.Referral, error)
+	IncrementUses(ctx context.Context, tx *sqlx.Tx, code string) (bool, error)
+	FindCodeOwner(ctx context.Context, code string) (uuid.UUID, error)
+	DeleteCode(ctx context.Context, userID uuid.UUID, code string) error
+	RotateCode(ctx context.Context, userID uuid.UUID, oldCode, newCode string) (domain.Referral, error)
+	DeleteExpiredCodes(ctx context.Context, limit int) (int64, error)
+	DeleteCodesByUserID(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error
+}
+
+type APIKey interface {
+	Create(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	FindByHash(ctx context.Context, keyHash string) (domain.APIKey, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID) ([]domain.APIKey, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	UpdateLastUsed(ctx context.Context, id uuid.UUID) error
 }
 
 type Repository struct {
 	User         User
 	RefreshToken RefreshToken
 	Referral     Referral
+	APIKey       APIKey
 }
 
-func NewRepository(db *sqlx.DB) *Repository {
+// NewRepository creates the repositories.
+//
+// Parameters:
+//   - db: The connection to the primary, used for writes.
+//   - replica: The connection to the read replica, used by read-only lookups; the primary
+//     if no replica is configured.
+//   - opts: The timeout and metrics settings of the queries.
+//
+// Returns:
+//   - *Repository: The repositories.
+func NewRepository(db, replica *sqlx.DB, opts postgres.QueryOptions) *Repository {
 	return &Repository{
-		User:         postgres.NewUserPostgres(db),
-		RefreshToken: postgres.NewRefreshTokenPostgres(db),
-		Referral:     postgres.NewReferralPostgres(db),
+		User:         postgres.NewUserPostgres(db, replica, opts),
+		RefreshToken: postgres.NewRefreshTokenPostgres(db, opts),
+		Referral:     postgres.NewReferralPostgres(db, replica, opts),
+		APIKey:       postgres.NewAPIKeyPostgres(db, opts),
 	}
 }
@@ -0,0 +1,116 @@
+// Package repotest provides a fake database for testing code that runs transactions
+// without a running Postgres.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// errNoQueries is returned for every statement, since the fake database only supports
+// transactions.
+var errNoQueries = errors.New("repotest: queries are not supported")
+
+// DB is a fake database that records the outcome of the transactions run on it.
+type DB struct {
+	*sqlx.DB
+
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+// NewDB creates a fake database that is closed when the test finishes.
+//
+// Parameters:
+//   - t: The test using the database.
+//
+// Returns:
+//   - *DB: The fake database.
+func NewDB(t testing.TB) *DB {
+	t.Helper()
+
+	d := &DB{}
+	d.DB = sqlx.NewDb(sql.OpenDB(connector{db: d}), "postgres")
+	t.Cleanup(func() { _ = d.DB.Close() })
+
+	return d
+}
+
+// Commits returns the number of committed transactions.
+func (d *DB) Commits() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.commits
+}
+
+// Rollbacks returns the number of rolled back transactions.
+func (d *DB) Rollbacks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.rollbacks
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn{db: c.db}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{db: c.db}
+}
+
+type fakeDriver struct {
+	db *DB
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return conn{db: d.db}, nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errNoQueries
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return tx{db: c.db}, nil
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t tx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	t.db.commits++
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	t.db.rollbacks++
+	return nil
+}
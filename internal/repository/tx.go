@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// WithTx runs fn inside a database transaction.
+//
+// The transaction is committed exactly once if fn returns nil, and rolled back if fn
+// returns an error or panics; the panic is re-raised after the rollback.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - db: A pointer to a sqlx database connection.
+//   - fn: The function to run with the transaction.
+//
+// Returns:
+//   - error: The error returned by fn, or an error if the transaction can't be started
+//     or committed.
+func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
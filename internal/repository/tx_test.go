@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/repository/repotest"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestWithTx(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name          string
+		fn            func(tx *sqlx.Tx) error
+		wantErr       error
+		wantCommits   int
+		wantRollbacks int
+	}{
+		{
+			name:        "commits on success",
+			fn:          func(tx *sqlx.Tx) error { return nil },
+			wantCommits: 1,
+		},
+		{
+			name:          "rolls back on error",
+			fn:            func(tx *sqlx.Tx) error { return errFailed },
+			wantErr:       errFailed,
+			wantRollbacks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := repotest.NewDB(t)
+
+			err := WithTx(context.Background(), db.DB, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := db.Commits(); got != tt.wantCommits {
+				t.Errorf("commits = %d, want %d", got, tt.wantCommits)
+			}
+
+			if got := db.Rollbacks(); got != tt.wantRollbacks {
+				t.Errorf("rollbacks = %d, want %d", got, tt.wantRollbacks)
+			}
+		})
+	}
+}
+
+func TestWithTx_Panic(t *testing.T) {
+	db := repotest.NewDB(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithTx() did not re-raise the panic")
+		}
+
+		if db.Commits() != 0 || db.Rollbacks() != 1 {
+			t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", db.Commits(), db.Rollbacks())
+		}
+	}()
+
+	_ = WithTx(context.Background(), db.DB, func(tx *sqlx.Tx) error {
+		panic("boom")
+	})
+}
@@ -2,12 +2,25 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"link-base/internal/config"
 	"net/http"
+	"time"
+)
+
+// Timeouts used when the configuration leaves them unset, so a server is never
+// exposed to slow clients holding connections open indefinitely.
+const (
+	defaultReadTimeout       = 10 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultWriteTimeout      = 10 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
 )
 
 type Server struct {
 	httpServer *http.Server
+	certFile   string
+	keyFile    string
 }
 
 // NewServer initializes and returns a new HTTP server instance
@@ -15,7 +28,8 @@ type Server struct {
 //
 // Parameters:
 //   - cfg: An HTTPConfig struct containing the server configuration such as port,
-//     maximum header bytes, read timeout, and write timeout.
+//     maximum header bytes, timeouts and TLS certificate. Unset timeouts fall back
+//     to the package defaults.
 //   - handler: An http.Handler instance that handles incoming HTTP requests.
 //
 // Returns:
@@ -23,25 +37,40 @@ type Server struct {
 func NewServer(cfg config.HTTPConfig, handler http.Handler) *Server {
 	return &Server{
 		httpServer: &http.Server{
-			Addr:           ":" + cfg.Port,
-			Handler:        handler,
-			MaxHeaderBytes: cfg.MaxHeaderBytes,
-			ReadTimeout:    cfg.ReadTimeout,
-			WriteTimeout:   cfg.WriteTimeout,
+			Addr:              ":" + cfg.Port,
+			Handler:           handler,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			ReadTimeout:       withDefault(cfg.ReadTimeout, defaultReadTimeout),
+			ReadHeaderTimeout: withDefault(cfg.ReadHeaderTimeout, defaultReadHeaderTimeout),
+			WriteTimeout:      withDefault(cfg.WriteTimeout, defaultWriteTimeout),
+			IdleTimeout:       withDefault(cfg.IdleTimeout, defaultIdleTimeout),
+			TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
 		},
+		certFile: cfg.TLS.CertFile,
+		keyFile:  cfg.TLS.KeyFile,
 	}
 }
 
 // Run starts the HTTP server and begins listening for incoming requests.
 //
-// The method returns an error if the server fails to start or if there is a
+// The server terminates TLS itself if a certificate and key file are configured and
+// serves plain HTTP otherwise. The method returns an error if the server fails to start or if there is a
 // problem with the underlying listener.
 //
 // Note: This method will block until the server is stopped by calling Stop.
 func (s *Server) Run() error {
+	if s.TLS() {
+		return s.httpServer.ListenAndServeTLS(s.certFile, s.keyFile)
+	}
+
 	return s.httpServer.ListenAndServe()
 }
 
+// TLS reports whether the server serves HTTPS.
+func (s *Server) TLS() bool {
+	return s.certFile != "" && s.keyFile != ""
+}
+
 // Stop gracefully stops the HTTP server and stops listening for incoming requests.
 //
 // The method returns an error if the server fails to stop gracefully.
@@ -50,3 +79,12 @@ func (s *Server) Run() error {
 func (s *Server) Stop(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
+
+// withDefault returns timeout, or def if timeout is not set.
+func withDefault(timeout, def time.Duration) time.Duration {
+	if timeout <= 0 {
+		return def
+	}
+
+	return timeout
+}
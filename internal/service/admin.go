@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"link-base/internal/cache"
+	"link-base/internal/config"
+	"link-base/internal/domain"
+	"link-base/internal/repository"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type AdminService struct {
+	db      *sqlx.DB
+	repos   *repository.Repository
+	redis   *cache.Cache
+	logger  *slog.Logger
+	authCfg config.AuthConfig
+}
+
+// NewAdminService creates a new instance of AdminService.
+//
+// Parameters:
+//   - deps: The shared service dependencies: database, repositories, cache, logger and the
+//     auth configuration.
+//
+// Returns:
+//   - *AdminService: A new instance of AdminService.
+func NewAdminService(deps Deps) *AdminService {
+	return &AdminService{
+		db:      deps.DB,
+		repos:   deps.Repos,
+		redis:   deps.Cache,
+		logger:  deps.Logger,
+		authCfg: deps.AuthConfig,
+	}
+}
+
+// ListUsers retrieves a page of active users for support staff.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - limit: The maximum number of users to return.
+//   - offset: The number of users to skip.
+//
+// Returns:
+//   - []domain.User: A page of users, newest first.
+//   - int: The total number of active users.
+//   - error: An error if there is a database query failure.
+func (a *AdminService) ListUsers(ctx context.Context, limit, offset int) ([]domain.User, int, error) {
+	total, err := a.repos.User.Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users, err := a.repos.User.List(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SearchUsers finds active users by the beginning of their email, ignoring case.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - query: The beginning of the email address.
+//   - limit: The maximum number of users to return.
+//
+// Returns:
+//   - []domain.User: The matching users, ordered by email.
+//   - error: An error if there is a database query failure.
+func (a *AdminService) SearchUsers(ctx context.Context, query string, limit int) ([]domain.User, error) {
+	return a.repos.User.SearchByEmailPrefix(ctx, strings.TrimSpace(query), limit)
+}
+
+// BanUser bans the user with the given ID and ends all their sessions.
+//
+// Banned users can't sign in, refresh tokens or use API keys. Access tokens issued before
+// the ban stay valid until they expire.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user to ban.
+//
+// Returns:
+//   - error: domain.ErrUserNotFound if there's no active user with the ID, or an error if
+//     the update fails; nothing is changed in that case.
+func (a *AdminService) BanUser(ctx context.Context, userID uuid.UUID) error {
+	err := repository.WithTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		if err := a.repos.User.SetBanned(ctx, tx, userID, true); err != nil {
+			return err
+		}
+
+		return a.repos.RefreshToken.DeleteByUserIDTx(ctx, tx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.authCfg.SessionStore == sessionStoreRedis {
+		if err := a.redis.Session.DeleteByUserID(ctx, userID, ""); err != nil {
+			a.logger.ErrorContext(ctx, "failed to delete cached sessions", slog.String("reason", err.Error()))
+		}
+	}
+
+	a.forgetUser(ctx, userID)
+	a.logger.InfoContext(ctx, "user banned", slog.String("userId", userID.String()))
+
+	return nil
+}
+
+// UnbanUser lifts the ban of the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user to unban.
+//
+// Returns:
+//   - error: domain.ErrUserNotFound if there's no active user with the ID, or an error if
+//     the update fails.
+func (a *AdminService) UnbanUser(ctx context.Context, userID uuid.UUID) error {
+	err := repository.WithTx(ctx, a.db, func(tx *sqlx.Tx) error {
+		return a.repos.User.SetBanned(ctx, tx, userID, false)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.forgetUser(ctx, userID)
+	a.logger.InfoContext(ctx, "user unbanned", slog.String("userId", userID.String()))
+
+	return nil
+}
+
+// forgetUser drops the cached user with the given ID, so sign ins see the new ban state.
+func (a *AdminService) forgetUser(ctx context.Context, userID uuid.UUID) {
+	user, err := a.repos.User.FindByUserIdPrimary(ctx, userID)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "failed to find user to forget", slog.String("reason", err.Error()))
+		return
+	}
+
+	if err := a.redis.User.Delete(ctx, user.Email); err != nil {
+		a.logger.ErrorContext(ctx, "failed to delete cached user", slog.String("reason", err.Error()))
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"link-base/internal/config"
+	"link-base/pkg/email"
+	"log/slog"
+	"time"
+)
+
+const (
+	emailDriverSMTP = "smtp"
+	emailDriverNoop = "noop"
+)
+
+// EmailSender delivers emails. Bodies starting with an HTML tag are sent as HTML.
+type EmailSender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// newEmailSender creates the EmailSender selected by the email driver.
+//
+// Parameters:
+//   - cfg: The email configuration selecting the driver.
+//   - smtpCfg: The SMTP configuration used by the smtp driver.
+//   - logger: A pointer to a slog logger used by the noop driver.
+//
+// Returns:
+//   - EmailSender: The email sender.
+//   - error: An error if the driver is unknown.
+func newEmailSender(cfg config.EmailConfig, smtpCfg config.SMPTConfig, logger *slog.Logger) (EmailSender, error) {
+	switch cfg.Driver {
+	case emailDriverSMTP, "":
+		return email.NewSMTPSender(smtpCfg), nil
+	case emailDriverNoop:
+		return email.NewNoopSender(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown email driver %q", cfg.Driver)
+	}
+}
+
+// sendWithRetry calls send until it succeeds, fails permanently or the configured
+// attempts are exhausted.
+//
+// The delay between attempts starts at the base delay and doubles after every failure.
+// Waiting stops early if the context is canceled.
+//
+// Parameters:
+//   - ctx: The context for controlling the retries.
+//   - cfg: A RetryConfig struct containing the maximum number of attempts and the base delay.
+//   - send: The function sending the email.
+//
+// Returns:
+//   - int: The number of attempts made.
+//   - error: The error of the last attempt, or nil if an attempt succeeded.
+func sendWithRetry(ctx context.Context, cfg config.RetryConfig, send func() error) (int, error) {
+	delay := cfg.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || email.IsPermanent(err) || attempt >= cfg.MaxAttempts {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"link-base/internal/config"
+	"link-base/internal/domain"
+	"unicode"
+	"unicode/utf8"
+)
+
+// checkPasswordPolicy validates the password against the configured policy.
+//
+// Parameters:
+//   - policy: The password policy to check against.
+//   - password: The password to validate.
+//
+// Returns:
+//   - error: A *domain.PasswordPolicyError listing every failed rule, or nil if the
+//     password satisfies the policy.
+func checkPasswordPolicy(policy config.PasswordPolicyConfig, password string) error {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	var failed []string
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		failed = append(failed, domain.PasswordRuleMinLength)
+	}
+
+	if policy.RequireUpper && !hasUpper {
+		failed = append(failed, domain.PasswordRuleUpper)
+	}
+
+	if policy.RequireLower && !hasLower {
+		failed = append(failed, domain.PasswordRuleLower)
+	}
+
+	if policy.RequireDigit && !hasDigit {
+		failed = append(failed, domain.PasswordRuleDigit)
+	}
+
+	if policy.RequireSymbol && !hasSymbol {
+		failed = append(failed, domain.PasswordRuleSymbol)
+	}
+
+	if len(failed) > 0 {
+		return &domain.PasswordPolicyError{Rules: failed}
+	}
+
+	return nil
+}
@@ -1,93 +1,386 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"html/template"
 	"link-base/internal/cache"
 	"link-base/internal/config"
 	"link-base/internal/domain"
+	"link-base/internal/metrics"
 	"link-base/internal/repository"
 	"link-base/pkg/auth"
-	"net/smtp"
+	"link-base/pkg/email"
+	"link-base/pkg/queue"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/skip2/go-qrcode"
 )
 
+// referralAliasRegexp matches the custom referral codes users may choose.
+var referralAliasRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{4,32}$`)
+
+// analyticsMaxRange is the largest range allowed per analytics bucket size.
+var analyticsMaxRange = map[string]time.Duration{
+	"hour":  7 * 24 * time.Hour,
+	"day":   366 * 24 * time.Hour,
+	"week":  2 * 366 * 24 * time.Hour,
+	"month": 5 * 366 * 24 * time.Hour,
+}
+
+// referralEmailData is passed to the referral email template.
+type referralEmailData struct {
+	Code string
+}
+
 type ReferralService struct {
+	db           *sqlx.DB
 	repos        *repository.Repository
 	redis        *cache.Cache
 	tokenManager *auth.Manager
-	cfg          config.SMPTConfig
+	logger       *slog.Logger
+	emailQueue   *queue.Queue
+	emailCfg     config.EmailConfig
+	emailSender  EmailSender
+	referralCfg  config.ReferralConfig
 }
 
 // NewReferralService creates a new instance of ReferralService.
 //
 // Parameters:
-//   - repos: A pointer to a Repository instance.
-//   - redis: A pointer to a Cache instance.
-//   - tokenManager: A pointer to a Manager instance.
+//   - deps: The shared service dependencies: database, repositories, cache, token manager,
+//     logger, email queue and the email and referral configuration.
+//   - emailSender: The sender of referral emails.
 //
 // Returns:
 //   - *ReferralService: A new instance of ReferralService.
-func NewReferralService(repos *repository.Repository, redis *cache.Cache, tokenManager *auth.Manager) *ReferralService {
+func NewReferralService(deps Deps, emailSender EmailSender) *ReferralService {
 	return &ReferralService{
-		repos:        repos,
-		redis:        redis,
-		tokenManager: tokenManager,
+		db:           deps.DB,
+		repos:        deps.Repos,
+		redis:        deps.Cache,
+		tokenManager: deps.TokenManager,
+		logger:       deps.Logger,
+		emailQueue:   deps.EmailQueue,
+		emailCfg:     deps.EmailConfig,
+		emailSender:  emailSender,
+		referralCfg:  deps.ReferralConfig,
 	}
 }
 
 // CreateCode creates a new referral code with the given user ID and TTL.
 //
+// A user can have at most the configured number of active (non-expired) codes; the limit is
+// enforced by the insert transaction, so concurrent requests can't exceed it. If an alias
+// is given it is used as the code, otherwise a random code is generated. The code is cached
+// within the insert transaction, so a code that is taken in Redis is never left in Postgres.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
-//   - input: A ReferralInput struct containing the user ID and TTL.
+//   - input: A ReferralInput struct containing the user ID, TTL, optional alias and
+//     max uses (0 for unlimited).
 //
 // Returns:
-//   - string: The referral code if created successfully.
-//   - error: An error if the referral code can't be created.
-func (r *ReferralService) CreateCode(ctx context.Context, input ReferralInput) (string, error) {
-	referralCode, err := r.generateReferralCode()
-	if err != nil {
-		return "", err
-	}
+//   - ReferralCode: The referral code and its shareable link if created successfully.
+//   - error: domain.ErrReferralCodeLimit if the user already has the maximum number of
+//     active codes, domain.ErrInvalidReferralAlias if the alias is malformed,
+//     domain.ErrInvalidReferralTTL if the TTL is outside the configured bounds,
+//     domain.ErrReferralCodeTaken if the alias is already in use, or an error if the
+//     referral code can't be created.
+func (r *ReferralService) CreateCode(ctx context.Context, input ReferralInput) (ReferralCode, error) {
+	ctx, span := tracer.Start(ctx, "ReferralService.CreateCode")
+	defer span.End()
 
-	res, err := r.repos.Referral.FindCodeByUserID(ctx, input.UserId)
-	if res != nil {
-		return "", fmt.Errorf("referral code %s already exists", res[0].ReferralCode)
+	if input.TTL <= 0 || input.TTL < r.referralCfg.MinTTL || input.TTL > r.referralCfg.MaxTTL {
+		return ReferralCode{}, fmt.Errorf("%w: must be between %s and %s",
+			domain.ErrInvalidReferralTTL, r.referralCfg.MinTTL, r.referralCfg.MaxTTL)
 	}
-	if err != nil {
-		return "", err
+
+	var err error
+	referralCode := input.Alias
+	if referralCode != "" {
+		if !referralAliasRegexp.MatchString(referralCode) {
+			return ReferralCode{}, domain.ErrInvalidReferralAlias
+		}
+	} else {
+		referralCode, err = r.generateReferralCode()
+		if err != nil {
+			return ReferralCode{}, err
+		}
 	}
 
 	referral := domain.Referral{
 		ReferralCode: referralCode,
 		UserId:       input.UserId,
 		TTL:          input.TTL,
+		MaxUses:      input.MaxUses,
 	}
 
-	if err = r.repos.Referral.CreateReferralCode(ctx, referral); err != nil {
-		return "", err
+	var cached bool
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if err := r.repos.Referral.CreateReferralCode(ctx, tx, referral, r.referralCfg.MaxActiveCodes); err != nil {
+			return err
+		}
+
+		// The code is cached before the commit, so a collision in Redis rolls the insert
+		// back instead of leaving a code behind that the client was told wasn't created.
+		if err := r.redis.Referral.Create(ctx, referral); err != nil {
+			return err
+		}
+		cached = true
+
+		return nil
+	})
+	if err != nil {
+		if cached {
+			r.forgetCode(ctx, referralCode)
+		}
+
+		return ReferralCode{}, err
+	}
+
+	metrics.ReferralCodesCreated.Inc()
+
+	link, err := r.link(referralCode)
+	if err != nil {
+		return ReferralCode{}, err
+	}
+
+	return ReferralCode{Code: referralCode, Link: link}, nil
+}
+
+// forgetCode drops a cached referral code whose insert failed to commit.
+func (r *ReferralService) forgetCode(ctx context.Context, code string) {
+	if err := r.redis.Referral.Delete(ctx, code); err != nil {
+		r.logger.ErrorContext(ctx, "failed to delete cached referral code", slog.String("reason", err.Error()))
+	}
+}
+
+// QRCode renders a PNG QR code encoding the shareable link of an active referral code
+// of the user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user owning the code.
+//   - code: The referral code.
+//   - size: The width and height of the image in pixels.
+//
+// Returns:
+//   - []byte: The PNG image.
+//   - error: domain.ErrReferralCodeNotFound if no active code matches,
+//     domain.ErrReferralCodeNotOwned if the code belongs to another user, or an error
+//     if the image can't be rendered.
+func (r *ReferralService) QRCode(ctx context.Context, userID uuid.UUID, code string, size int) ([]byte, error) {
+	if err := r.checkCodeOwner(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	link, err := r.link(code)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = r.redis.Referral.Create(ctx, referral); err != nil {
+	return qrcode.Encode(link, qrcode.Medium, size)
+}
+
+// Link returns the shareable sign up URL of an active referral code of the user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user owning the code.
+//   - code: The referral code.
+//
+// Returns:
+//   - string: The sign up URL with the code as the ref query parameter.
+//   - error: domain.ErrReferralCodeNotFound if no active code matches,
+//     domain.ErrReferralCodeNotOwned if the code belongs to another user, or an error
+//     if the owner can't be looked up.
+func (r *ReferralService) Link(ctx context.Context, userID uuid.UUID, code string) (string, error) {
+	if err := r.checkCodeOwner(ctx, userID, code); err != nil {
 		return "", err
 	}
 
-	return referralCode, nil
+	return r.link(code)
+}
+
+// checkCodeOwner verifies that the referral code is active and belongs to the user.
+func (r *ReferralService) checkCodeOwner(ctx context.Context, userID uuid.UUID, code string) error {
+	owner, err := r.repos.Referral.FindCodeOwner(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	if owner != userID {
+		return domain.ErrReferralCodeNotOwned
+	}
+
+	return nil
+}
+
+// link builds the shareable sign up URL of the referral code.
+func (r *ReferralService) link(code string) (string, error) {
+	u, err := url.Parse(r.referralCfg.LinkURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid referral link URL: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("ref", code)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// RevokeCode deletes an active referral code of the user before it expires.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user revoking the code.
+//   - code: The referral code to be revoked.
+//
+// Returns:
+//   - error: domain.ErrReferralCodeNotFound if no active code matches,
+//     domain.ErrReferralCodeNotOwned if the code belongs to another user, or an error
+//     if the code can't be deleted.
+func (r *ReferralService) RevokeCode(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := r.checkCodeOwner(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := r.repos.Referral.DeleteCode(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return r.redis.Referral.Delete(ctx, code)
 }
 
-// FindReferralByUserID retrieves all referral user IDs associated with the given user ID.
+// RegenerateCode replaces an active referral code of the user with a fresh random code.
+//
+// The new code keeps the TTL and max uses of the old one. Both codes are swapped in one
+// database statement, so the user never ends up with neither or both.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user owning the code.
+//   - code: The referral code to be replaced.
+//
+// Returns:
+//   - ReferralCode: The new referral code and its shareable link.
+//   - error: domain.ErrReferralCodeNotFound if no active code matches,
+//     domain.ErrReferralCodeNotOwned if the code belongs to another user, or an error
+//     if the code can't be replaced.
+func (r *ReferralService) RegenerateCode(ctx context.Context, userID uuid.UUID, code string) (ReferralCode, error) {
+	ctx, span := tracer.Start(ctx, "ReferralService.RegenerateCode")
+	defer span.End()
+
+	if err := r.checkCodeOwner(ctx, userID, code); err != nil {
+		return ReferralCode{}, err
+	}
+
+	newCode, err := r.generateReferralCode()
+	if err != nil {
+		return ReferralCode{}, err
+	}
+
+	referral, err := r.repos.Referral.RotateCode(ctx, userID, code, newCode)
+	if err != nil {
+		return ReferralCode{}, err
+	}
+
+	if err := r.redis.Referral.Delete(ctx, code); err != nil {
+		return ReferralCode{}, err
+	}
+
+	if err := r.redis.Referral.Create(ctx, referral); err != nil {
+		return ReferralCode{}, err
+	}
+
+	metrics.ReferralCodesCreated.Inc()
+
+	link, err := r.link(newCode)
+	if err != nil {
+		return ReferralCode{}, err
+	}
+
+	return ReferralCode{Code: newCode, Link: link}, nil
+}
+
+// FindReferralByUserID retrieves a page of referral user IDs associated with the given user ID.
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - id: The UUID of the user whose referral IDs are to be retrieved.
+//   - limit: The maximum number of referral user IDs to return.
+//   - offset: The number of referral user IDs to skip.
 //
 // Returns:
-//   - []uuid.UUID: A slice of referral user IDs if found.
+//   - []uuid.UUID: A page of referral user IDs, empty if offset is beyond the end.
+//   - int: The total number of referral user IDs.
 //   - error: An error if there is a database query failure.
-func (r *ReferralService) FindReferralByUserID(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
-	return r.repos.Referral.FindReferralByUserID(ctx, id)
+func (r *ReferralService) FindReferralByUserID(ctx context.Context, id uuid.UUID, limit, offset int) ([]uuid.UUID, int, error) {
+	total, err := r.repos.Referral.CountReferred(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users, err := r.repos.Referral.FindReferralByUserID(ctx, id, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// CountReferred returns the number of users the given user has referred.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the referrer.
+//
+// Returns:
+//   - int: The number of referred users.
+//   - error: An error if there is a database query failure.
+func (r *ReferralService) CountReferred(ctx context.Context, userID uuid.UUID) (int, error) {
+	return r.repos.Referral.CountReferred(ctx, userID)
+}
+
+// CountReferredByCode returns the number of users the given user has referred per referral code.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the referrer.
+//
+// Returns:
+//   - []domain.ReferralCodeCount: The referred sign ups per code.
+//   - error: An error if there is a database query failure.
+func (r *ReferralService) CountReferredByCode(ctx context.Context, userID uuid.UUID) ([]domain.ReferralCodeCount, error) {
+	return r.repos.Referral.CountReferredByCode(ctx, userID)
+}
+
+// Analytics returns the number of users referred by the given user per time bucket.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - input: The ReferralAnalyticsInput containing the user ID, range and bucket size
+//     (hour, day, week or month).
+//
+// Returns:
+//   - []domain.ReferralBucket: The referred sign ups per bucket.
+//   - error: domain.ErrInvalidAnalyticsRange if the bucket is unknown, the range is empty
+//     or too large for the bucket size, or an error if there is a database query failure.
+func (r *ReferralService) Analytics(ctx context.Context, input ReferralAnalyticsInput) ([]domain.ReferralBucket, error) {
+	maxRange, ok := analyticsMaxRange[input.Bucket]
+	if !ok || !input.To.After(input.From) || input.To.Sub(input.From) > maxRange {
+		return nil, domain.ErrInvalidAnalyticsRange
+	}
+
+	return r.repos.Referral.CountReferredByBucket(ctx, input.UserId, input.Bucket, input.From, input.To)
 }
 
 // generateReferralCode generates a new cryptographically secure referral code.
@@ -99,31 +392,79 @@ func (r *ReferralService) generateReferralCode() (string, error) {
 	return r.tokenManager.NewRefreshToken()
 }
 
-// SendEmail sends an email containing the referral code to the specified email address.
+// findCodes returns the active referral codes of the user from the cache, falling back
+// to the database if the cache holds none.
+func (r *ReferralService) findCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes, err := r.redis.Referral.FindCodesByUserID(ctx, userID)
+	if err == nil && len(codes) > 0 {
+		return codes, nil
+	}
+
+	referrals, err := r.repos.Referral.FindCodeByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes = make([]string, 0, len(referrals))
+	for _, referral := range referrals {
+		codes = append(codes, referral.ReferralCode)
+	}
+
+	return codes, nil
+}
+
+// SendEmail sends an HTML email containing the user's first active referral code to the
+// specified email address. The body is rendered from the configured template and the
+// email is sent in the background by the email queue.
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - userId: The UUID of the user whose referral code is to be sent.
-//   - email: The recipient's email address.
+//   - to: The recipient's email address.
 //
 // Returns:
-//   - error: An error if sending the email fails.
-func (r *ReferralService) SendEmail(ctx context.Context, userId uuid.UUID, email string) error {
-	referralCode, err := r.repos.Referral.FindCodeByUserID(ctx, userId)
+//   - error: domain.ErrReferralCodeNotFound if the user has no active code,
+//     domain.ErrEmailUnavailable if the email queue can't take the email, or an error
+//     if the email can't be rendered.
+func (r *ReferralService) SendEmail(ctx context.Context, userId uuid.UUID, to string) error {
+	ctx, span := tracer.Start(ctx, "ReferralService.SendEmail")
+	defer span.End()
+
+	codes, err := r.findCodes(ctx, userId)
 	if err != nil {
 		return err
 	}
 
-	from := userId.String()
-	subject := "Your Referral Code"
-	body := fmt.Sprintf("Hello!\n\nYour referral code is: %s\n\nBest regards!", referralCode)
-	message := []byte("Subject: " + subject + "\n\n" + body)
+	if len(codes) == 0 {
+		return domain.ErrReferralCodeNotFound
+	}
+
+	tmpl, err := template.ParseFiles(r.referralCfg.EmailTemplate)
+	if err != nil {
+		return fmt.Errorf("parse referral email template: %w", err)
+	}
 
-	smtpAuth := smtp.PlainAuth("", r.cfg.SMPTUser, r.cfg.SMPTPassword, r.cfg.SMPTHost)
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, referralEmailData{Code: codes[0]}); err != nil {
+		return fmt.Errorf("render referral email: %w", err)
+	}
 
-	err = smtp.SendMail(r.cfg.SMPTHost+":"+r.cfg.SMPTPort, smtpAuth, from, []string{email}, message)
+	err = r.emailQueue.Enqueue(func(ctx context.Context) {
+		attempts, err := sendWithRetry(ctx, r.emailCfg.Retry, func() error {
+			return r.emailSender.Send(ctx, to, "Your Referral Code", body.String())
+		})
+		if err != nil {
+			r.logger.ErrorContext(ctx, "referral email dead-lettered",
+				slog.String("userId", userId.String()),
+				slog.String("to", to),
+				slog.Int("attempts", attempts),
+				slog.Bool("permanent", email.IsPermanent(err)),
+				slog.String("reason", err.Error()),
+			)
+		}
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", domain.ErrEmailUnavailable, err)
 	}
 
 	return nil
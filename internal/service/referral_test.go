@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"link-base/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReferralService_CreateCode_CacheCollisionRollsBack(t *testing.T) {
+	svc, env := newTestReferralService(t)
+	env.referralCache.owners["taken"] = uuid.New()
+
+	_, err := svc.CreateCode(context.Background(), ReferralInput{UserId: uuid.New(), TTL: time.Hour, Alias: "taken"})
+	if !errors.Is(err, domain.ErrReferralCodeTaken) {
+		t.Fatalf("CreateCode() error = %v, want %v", err, domain.ErrReferralCodeTaken)
+	}
+
+	if env.db.Commits() != 0 || env.db.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", env.db.Commits(), env.db.Rollbacks())
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"link-base/internal/cache"
 	"link-base/internal/config"
+	"link-base/internal/domain"
 	"link-base/internal/repository"
 	"link-base/pkg/auth"
 	"link-base/pkg/hash"
+	"link-base/pkg/queue"
+	"link-base/pkg/secret"
 	"log/slog"
 	"time"
 
@@ -14,48 +17,179 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// Tokens is the result of a sign in. If the user has two-factor authentication
+// enabled, only TwoFactorChallenge is set and the session is created by
+// CompleteTwoFactor.
 type Tokens struct {
-	AccessToken  string
-	RefreshToken string
+	AccessToken        string
+	RefreshToken       string
+	TwoFactorChallenge string
+}
+
+// UserExport is everything stored about a user, as returned by the data export.
+type UserExport struct {
+	User          domain.User
+	ReferralCodes []domain.Referral
+	Referred      []uuid.UUID
+	Sessions      []domain.RefreshToken
+	APIKeys       []domain.APIKey
+}
+
+// TwoFactorEnrollment is the TOTP secret of a pending enrollment.
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// ClientInfo describes the client a session is created for. Both fields are optional.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
 }
 
 type SignInInput struct {
-	Email    string
-	Password string
+	Email     string
+	Password  string
+	IP        string
+	UserAgent string
 }
 
 type SignUpInput struct {
 	Email        string
 	Password     string
 	ReferralCode string
+	IP           string
+	UserAgent    string
 }
 
 type ReferralInput struct {
+	UserId  uuid.UUID
+	TTL     time.Duration
+	Alias   string
+	MaxUses int
+}
+
+// ReferralCode is a created referral code together with its shareable sign up link.
+type ReferralCode struct {
+	Code string
+	Link string
+}
+
+type ReferralAnalyticsInput struct {
 	UserId uuid.UUID
-	TTL    time.Duration
+	From   time.Time
+	To     time.Time
+	Bucket string
 }
 
 type User interface {
 	SignIn(ctx context.Context, input SignInInput) (Tokens, error)
 	SignUp(ctx context.Context, input SignUpInput) (Tokens, error)
-	RefreshTokens(ctx context.Context, refreshToken string) (Tokens, error)
+	RefreshTokens(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error)
+	RevokeToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	Logout(ctx context.Context, userID uuid.UUID, jti string, ttl time.Duration) error
+	LogoutOthers(ctx context.Context, userID uuid.UUID, currentRefreshToken string) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.RefreshToken, error)
+	RequestMagicLink(ctx context.Context, email string) error
+	SignInWithMagicLink(ctx context.Context, token string, client ClientInfo) (Tokens, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	DeleteAccount(ctx context.Context, userID uuid.UUID) error
+	GetProfile(ctx context.Context, userID uuid.UUID) (domain.User, error)
+	ExportData(ctx context.Context, userID uuid.UUID) (UserExport, error)
+	GetPoints(ctx context.Context, userID uuid.UUID) (int64, error)
+	EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (TwoFactorEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) error
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error
+	CompleteTwoFactor(ctx context.Context, challenge, code string, client ClientInfo) (Tokens, error)
 }
 
 type Referral interface {
-	CreateCode(ctx context.Context, input ReferralInput) (string, error)
-	FindReferralByUserID(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	CreateCode(ctx context.Context, input ReferralInput) (ReferralCode, error)
+	RevokeCode(ctx context.Context, userID uuid.UUID, code string) error
+	QRCode(ctx context.Context, userID uuid.UUID, code string, size int) ([]byte, error)
+	Link(ctx context.Context, userID uuid.UUID, code string) (string, error)
+	RegenerateCode(ctx context.Context, userID uuid.UUID, code string) (ReferralCode, error)
+	FindReferralByUserID(ctx context.Context, id uuid.UUID, limit, offset int) ([]uuid.UUID, int, error)
+	CountReferred(ctx context.Context, userID uuid.UUID) (int, error)
+	CountReferredByCode(ctx context.Context, userID uuid.UUID) ([]domain.ReferralCodeCount, error)
+	Analytics(ctx context.Context, input ReferralAnalyticsInput) ([]domain.ReferralBucket, error)
 	SendEmail(ctx context.Context, userId uuid.UUID, email string) error
 }
 
+type APIKey interface {
+	Create(ctx context.Context, userID uuid.UUID, name string) (domain.APIKey, string, error)
+	List(ctx context.Context, userID uuid.UUID) ([]domain.APIKey, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	Authenticate(ctx context.Context, key string) (domain.User, error)
+}
+
+type Admin interface {
+	ListUsers(ctx context.Context, limit, offset int) ([]domain.User, int, error)
+	SearchUsers(ctx context.Context, query string, limit int) ([]domain.User, error)
+	BanUser(ctx context.Context, userID uuid.UUID) error
+	UnbanUser(ctx context.Context, userID uuid.UUID) error
+}
+
 type Service struct {
 	User     User
 	Referral Referral
+	APIKey   APIKey
+	Admin    Admin
 }
 
-func NewService(repos *repository.Repository, logger *slog.Logger,
-	cfg config.JWTConfig, tokenManager *auth.Manager, hasher *hash.SHA1Hasher, db *sqlx.DB, redis *cache.Cache) *Service {
-	return &Service{
-		User:     NewUserService(repos, logger, cfg, tokenManager, hasher, db, redis),
-		Referral: NewReferralService(repos, redis, tokenManager),
+// Deps holds the dependencies shared by the services.
+type Deps struct {
+	Repos          *repository.Repository
+	Cache          *cache.Cache
+	Logger         *slog.Logger
+	DB             *sqlx.DB
+	TokenManager   *auth.Manager
+	Hasher         hash.Hasher
+	JWTConfig      config.JWTConfig
+	AuthConfig     config.AuthConfig
+	SMPTConfig     config.SMPTConfig
+	EmailConfig    config.EmailConfig
+	ReferralConfig config.ReferralConfig
+	EmailQueue     *queue.Queue
+	// WebhookQueue delivers the referral webhooks in the background.
+	WebhookQueue *queue.Queue
+	// SecretCipher encrypts TOTP secrets; two-factor enrollment is unavailable if nil.
+	SecretCipher *secret.Cipher
+	// EmailSender overrides the sender selected by EmailConfig, e.g. with a mock.
+	EmailSender EmailSender
+}
+
+// NewService creates the services.
+//
+// Unless deps provides an EmailSender, the sender is constructed from the email
+// configuration.
+//
+// Parameters:
+//   - deps: The shared service dependencies.
+//
+// Returns:
+//   - *Service: The services.
+//   - error: An error if the email sender can't be created.
+func NewService(deps Deps) (*Service, error) {
+	sender := deps.EmailSender
+	if sender == nil {
+		var err error
+		if sender, err = newEmailSender(deps.EmailConfig, deps.SMPTConfig, deps.Logger); err != nil {
+			return nil, err
+		}
 	}
+
+	return &Service{
+		User:     NewUserService(deps, sender),
+		Referral: NewReferralService(deps, sender),
+		APIKey:   NewAPIKeyService(deps),
+		Admin:    NewAdminService(deps),
+	}, nil
 }
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"io"
+	"link-base/internal/cache"
+	"link-base/internal/config"
+	"link-base/internal/domain"
+	"link-base/internal/repository"
+	"link-base/internal/repository/repotest"
+	"link-base/pkg/auth"
+	"link-base/pkg/hash"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// testSalt is the global salt of the legacy SHA1 hashes.
+const testSalt = "salt"
+
+// testEnv holds the fakes behind the services created by newTestDeps.
+type testEnv struct {
+	db            *repotest.DB
+	users         *fakeUserRepo
+	sessions      *fakeRefreshTokenRepo
+	referrals     *fakeReferralRepo
+	sessionCache  *fakeSessionCache
+	userCache     *fakeUserCache
+	referralCache *fakeReferralCache
+	emailChange   *fakeTokenCache
+}
+
+// newTestUserService creates a UserService backed by in-memory fakes that hashes new
+// passwords with bcrypt.
+func newTestUserService(t *testing.T) (*UserService, *testEnv) {
+	t.Helper()
+
+	deps, env := newTestDeps(t)
+
+	return NewUserService(deps, fakeEmailSender{}), env
+}
+
+// newTestReferralService creates a ReferralService backed by in-memory fakes.
+func newTestReferralService(t *testing.T) (*ReferralService, *testEnv) {
+	t.Helper()
+
+	deps, env := newTestDeps(t)
+
+	return NewReferralService(deps, fakeEmailSender{}), env
+}
+
+// newTestDeps creates service dependencies backed by in-memory fakes.
+func newTestDeps(t *testing.T) (Deps, *testEnv) {
+	t.Helper()
+
+	tokenManager, err := auth.NewManager(config.JWTConfig{SigningKey: "secret"})
+	if err != nil {
+		t.Fatalf("create token manager: %v", err)
+	}
+
+	hasher, err := hash.NewHasher(config.HashConfig{
+		Algorithm: hash.AlgorithmBcrypt,
+		Salt:      testSalt,
+		Bcrypt:    config.BcryptConfig{Cost: 4},
+	})
+	if err != nil {
+		t.Fatalf("create hasher: %v", err)
+	}
+
+	env := &testEnv{
+		db:            repotest.NewDB(t),
+		users:         &fakeUserRepo{users: map[uuid.UUID]domain.User{}},
+		sessions:      &fakeRefreshTokenRepo{sessions: map[string]uuid.UUID{}},
+		sessionCache:  &fakeSessionCache{sessions: map[string]uuid.UUID{}},
+		referrals:     &fakeReferralRepo{},
+		userCache:     &fakeUserCache{users: map[string]domain.User{}},
+		referralCache: &fakeReferralCache{owners: map[string]uuid.UUID{}},
+		emailChange:   newFakeTokenCache(),
+	}
+
+	deps := Deps{
+		Repos: &repository.Repository{
+			User:         env.users,
+			RefreshToken: env.sessions,
+			Referral:     env.referrals,
+		},
+		Cache: &cache.Cache{
+			Referral:      env.referralCache,
+			Verification:  newFakeTokenCache(),
+			EmailChange:   env.emailChange,
+			LoginAttempts: fakeLoginAttempts{},
+			User:          env.userCache,
+			Session:       env.sessionCache,
+		},
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DB:           env.db.DB,
+		TokenManager: tokenManager,
+		Hasher:       hasher,
+		JWTConfig: config.JWTConfig{
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+		},
+		AuthConfig: config.AuthConfig{
+			Lockout: config.LockoutConfig{MaxAttempts: 5},
+		},
+		ReferralConfig: config.ReferralConfig{
+			MaxActiveCodes: 1,
+			MaxTTL:         time.Hour,
+			LinkURL:        "http://localhost:8080/signup",
+		},
+	}
+
+	return deps, env
+}
+
+// fakeUserRepo is an in-memory user repository with a unique email index.
+type fakeUserRepo struct {
+	repository.User
+
+	mu               sync.Mutex
+	users            map[uuid.UUID]domain.User
+	findByEmailCalls int
+	lastLoginUpdates int
+
+	// beforeUpdateEmail runs before the email is updated, outside the lock.
+	beforeUpdateEmail func()
+}
+
+func (r *fakeUserRepo) add(user domain.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.UserId] = user
+}
+
+func (r *fakeUserRepo) get(id uuid.UUID) domain.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.users[id]
+}
+
+func (r *fakeUserRepo) Create(_ context.Context, _ *sqlx.Tx, user domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return domain.ErrEmailInUse
+		}
+	}
+
+	r.users[user.UserId] = user
+	return nil
+}
+
+func (r *fakeUserRepo) FindByUserId(_ context.Context, id uuid.UUID) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (r *fakeUserRepo) FindByUserIdPrimary(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return r.FindByUserId(ctx, id)
+}
+
+func (r *fakeUserRepo) FindByEmail(_ context.Context, email string) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.findByEmailCalls++
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return domain.User{}, domain.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) UpdatePasswordHash(_ context.Context, id uuid.UUID, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.users[id]
+	user.PasswordHash = passwordHash
+	r.users[id] = user
+	return nil
+}
+
+func (r *fakeUserRepo) FindByEmailPrimary(ctx context.Context, email string) (domain.User, error) {
+	return r.FindByEmail(ctx, email)
+}
+
+func (r *fakeUserRepo) UpdateEmail(_ context.Context, id uuid.UUID, email string) error {
+	if r.beforeUpdateEmail != nil {
+		r.beforeUpdateEmail()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.users[id]
+	user.Email = email
+	r.users[id] = user
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateLastLogin(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	user := r.users[id]
+	user.LastLoginAt = &now
+	r.users[id] = user
+	r.lastLoginUpdates++
+	return nil
+}
+
+// fakeRefreshTokenRepo is an in-memory refresh token repository.
+type fakeRefreshTokenRepo struct {
+	repository.RefreshToken
+
+	mu           sync.Mutex
+	sessions     map[string]uuid.UUID
+	consumeCalls int
+}
+
+func (r *fakeRefreshTokenRepo) Create(_ context.Context, session domain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.RefreshToken] = session.UserID
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) DeleteByRefreshToken(_ context.Context, refreshToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[refreshToken]; !ok {
+		return domain.ErrSessionNotFound
+	}
+
+	delete(r.sessions, refreshToken)
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) Consume(_ context.Context, refreshToken string) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.consumeCalls++
+	userID, ok := r.sessions[refreshToken]
+	if !ok {
+		return uuid.Nil, domain.ErrSessionNotFound
+	}
+
+	delete(r.sessions, refreshToken)
+	return userID, nil
+}
+
+func (r *fakeRefreshTokenRepo) DeleteByUserID(context.Context, uuid.UUID) error {
+	return nil
+}
+
+// fakeReferralRepo knows no referral codes and counts the referral writes.
+type fakeReferralRepo struct {
+	repository.Referral
+
+	incrementUsesCalls  int
+	createReferralCalls int
+}
+
+func (r *fakeReferralRepo) CreateReferralCode(context.Context, *sqlx.Tx, domain.Referral, int) error {
+	return nil
+}
+
+func (r *fakeReferralRepo) FindCodeOwner(context.Context, string) (uuid.UUID, error) {
+	return uuid.Nil, domain.ErrReferralCodeNotFound
+}
+
+func (r *fakeReferralRepo) IncrementUses(context.Context, *sqlx.Tx, string) (bool, error) {
+	r.incrementUsesCalls++
+	return true, nil
+}
+
+func (r *fakeReferralRepo) CreateReferral(context.Context, *sqlx.Tx, domain.ReferralUser) (bool, error) {
+	r.createReferralCalls++
+	return true, nil
+}
+
+// fakeUserCache is an in-memory user cache.
+type fakeUserCache struct {
+	mu    sync.Mutex
+	users map[string]domain.User
+}
+
+func (c *fakeUserCache) Get(_ context.Context, email string) (domain.User, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.users[email]
+	return user, ok, nil
+}
+
+func (c *fakeUserCache) Set(_ context.Context, user domain.User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.users[user.Email] = user
+	return nil
+}
+
+func (c *fakeUserCache) Delete(_ context.Context, email string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.users, email)
+	return nil
+}
+
+func (c *fakeUserCache) has(email string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.users[email]
+	return ok
+}
+
+// fakeReferralCache resolves the referral codes it was given.
+type fakeReferralCache struct {
+	cache.Referral
+
+	owners map[string]uuid.UUID
+}
+
+func (c *fakeReferralCache) Create(_ context.Context, referral domain.Referral) error {
+	if _, ok := c.owners[referral.ReferralCode]; ok {
+		return domain.ErrReferralCodeTaken
+	}
+
+	c.owners[referral.ReferralCode] = referral.UserId
+	return nil
+}
+
+func (c *fakeReferralCache) FindByReferralCode(_ context.Context, code string) (uuid.UUID, error) {
+	owner, ok := c.owners[code]
+	if !ok {
+		return uuid.Nil, domain.ErrReferralCodeNotFound
+	}
+
+	return owner, nil
+}
+
+// fakeSessionCache is an in-memory session store.
+type fakeSessionCache struct {
+	cache.Session
+
+	mu       sync.Mutex
+	sessions map[string]uuid.UUID
+}
+
+func (c *fakeSessionCache) Create(_ context.Context, refreshToken string, userID uuid.UUID, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sessions[refreshToken] = userID
+	return nil
+}
+
+func (c *fakeSessionCache) Consume(_ context.Context, refreshToken string) (uuid.UUID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	userID, ok := c.sessions[refreshToken]
+	if !ok {
+		return uuid.Nil, domain.ErrSessionNotFound
+	}
+
+	delete(c.sessions, refreshToken)
+	return userID, nil
+}
+
+// fakeTokenCache is an in-memory single-use token store.
+type fakeTokenCache struct {
+	cache.Token
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func newFakeTokenCache() *fakeTokenCache {
+	return &fakeTokenCache{tokens: map[string]string{}}
+}
+
+func (c *fakeTokenCache) Create(_ context.Context, token, value string, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens[token] = value
+	return nil
+}
+
+func (c *fakeTokenCache) Consume(_ context.Context, token string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.tokens[token]
+	if !ok {
+		return "", domain.ErrTokenNotFound
+	}
+
+	delete(c.tokens, token)
+	return value, nil
+}
+
+// fakeLoginAttempts never locks a sign in.
+type fakeLoginAttempts struct{}
+
+func (fakeLoginAttempts) Increment(context.Context, string, time.Duration) (int64, error) {
+	return 1, nil
+}
+
+func (fakeLoginAttempts) Lock(context.Context, string, time.Duration) error {
+	return nil
+}
+
+func (fakeLoginAttempts) IsLocked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (fakeLoginAttempts) Reset(context.Context, string) error {
+	return nil
+}
+
+// fakeEmailSender drops every email.
+type fakeEmailSender struct{}
+
+func (fakeEmailSender) Send(context.Context, string, string, string) error {
+	return nil
+}
@@ -0,0 +1,7 @@
+package service
+
+import "go.opentelemetry.io/otel"
+
+// tracer creates the spans of the service methods. The repository and cache calls
+// made with the span's context show up as its children.
+var tracer = otel.Tracer("link-base/internal/service")
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"link-base/internal/domain"
+	"link-base/pkg/totp"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// completeSignIn finishes a successful first-factor sign in.
+//
+// Users without two-factor authentication get a new session right away. For users with
+// 2FA enabled a single-use challenge is stored for the configured TTL instead, which
+// CompleteTwoFactor exchanges for a session together with a valid code.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - user: The authenticated user.
+//   - client: The client the session is created for.
+//
+// Returns:
+//   - Tokens: The session tokens, or only the two-factor challenge.
+//   - error: An error if the challenge or the session can't be created.
+func (u *UserService) completeSignIn(ctx context.Context, user domain.User, client ClientInfo) (Tokens, error) {
+	if user.TOTPEnabled {
+		challenge, err := u.tokenManager.NewRefreshToken()
+		if err != nil {
+			return Tokens{}, err
+		}
+
+		if err := u.redis.TwoFactor.Create(ctx, challenge, user.UserId.String(), u.authCfg.TwoFactor.ChallengeTTL); err != nil {
+			return Tokens{}, err
+		}
+
+		return Tokens{TwoFactorChallenge: challenge}, nil
+	}
+
+	if err := u.repos.User.UpdateLastLogin(ctx, user.UserId); err != nil {
+		u.logger.ErrorContext(ctx, "failed to update last login", slog.String("reason", err.Error()))
+	}
+
+	return u.createSession(ctx, user.UserId, client)
+}
+
+// CompleteTwoFactor exchanges a two-factor challenge and a TOTP code for a session.
+//
+// The challenge is consumed even if the code is wrong, so every guess requires signing
+// in with the password again.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - challenge: The challenge returned by the first-factor sign in.
+//   - code: The current code of the user's authenticator app.
+//   - client: The client the session is created for.
+//
+// Returns:
+//   - Tokens: The access and refresh tokens of the new session.
+//   - error: domain.ErrTokenNotFound if the challenge is unknown, expired or already used,
+//     domain.ErrInvalidTwoFactorCode if the code doesn't match, or an error if the
+//     session can't be created.
+func (u *UserService) CompleteTwoFactor(ctx context.Context, challenge, code string, client ClientInfo) (Tokens, error) {
+	value, err := u.redis.TwoFactor.Consume(ctx, challenge)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("invalid user ID in two-factor challenge: %w", err)
+	}
+
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := u.checkTOTP(user, code); err != nil {
+		return Tokens{}, err
+	}
+
+	if err := u.repos.User.UpdateLastLogin(ctx, user.UserId); err != nil {
+		u.logger.ErrorContext(ctx, "failed to update last login", slog.String("reason", err.Error()))
+	}
+
+	return u.createSession(ctx, user.UserId, client)
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user and stores it encrypted.
+//
+// The secret is pending until ConfirmTwoFactor proves the authenticator app was set up,
+// so enrolling again before confirming simply replaces it.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//
+// Returns:
+//   - TwoFactorEnrollment: The secret and the otpauth URI to show to the user.
+//   - error: domain.ErrTwoFactorUnavailable if no encryption key is configured,
+//     domain.ErrTwoFactorEnabled if 2FA is already enabled, or a database error.
+func (u *UserService) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (TwoFactorEnrollment, error) {
+	if u.cipher == nil {
+		return TwoFactorEnrollment{}, domain.ErrTwoFactorUnavailable
+	}
+
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if user.TOTPEnabled {
+		return TwoFactorEnrollment{}, domain.ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	encrypted, err := u.cipher.Encrypt(secret)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if err := u.repos.User.SetTOTPSecret(ctx, userID, encrypted); err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	u.forgetUser(ctx, user.Email)
+
+	return TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(u.authCfg.TwoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves the pending
+// secret works by entering a code.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//   - code: The current code of the user's authenticator app.
+//
+// Returns:
+//   - error: domain.ErrTwoFactorEnabled if 2FA is already enabled, domain.ErrTwoFactorNotEnrolled
+//     if there's no pending secret, domain.ErrInvalidTwoFactorCode if the code doesn't
+//     match, or a database error.
+func (u *UserService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	// The pending secret may have been stored moments ago, before the replica has it.
+	user, err := u.repos.User.FindByUserIdPrimary(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		return domain.ErrTwoFactorEnabled
+	}
+
+	if err := u.checkTOTP(user, code); err != nil {
+		return err
+	}
+
+	if err := u.repos.User.SetTOTPEnabled(ctx, userID, true); err != nil {
+		return err
+	}
+
+	u.forgetUser(ctx, user.Email)
+
+	return nil
+}
+
+// DisableTwoFactor turns off two-factor authentication and discards the secret.
+//
+// A valid code is required, so a stolen access token alone can't remove the second factor.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//   - code: The current code of the user's authenticator app.
+//
+// Returns:
+//   - error: domain.ErrTwoFactorNotEnrolled if 2FA is not enabled, domain.ErrInvalidTwoFactorCode
+//     if the code doesn't match, or a database error.
+func (u *UserService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TOTPEnabled {
+		return domain.ErrTwoFactorNotEnrolled
+	}
+
+	if err := u.checkTOTP(user, code); err != nil {
+		return err
+	}
+
+	if err := u.repos.User.SetTOTPEnabled(ctx, userID, false); err != nil {
+		return err
+	}
+
+	u.forgetUser(ctx, user.Email)
+
+	return nil
+}
+
+// checkTOTP decrypts the user's TOTP secret and validates the code against it,
+// accepting the configured number of adjacent time steps.
+func (u *UserService) checkTOTP(user domain.User, code string) error {
+	if user.TOTPSecret == "" {
+		return domain.ErrTwoFactorNotEnrolled
+	}
+
+	if u.cipher == nil {
+		return domain.ErrTwoFactorUnavailable
+	}
+
+	secret, err := u.cipher.Decrypt(user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	if !totp.Validate(secret, code, time.Now(), u.authCfg.TwoFactor.Skew) {
+		return domain.ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
@@ -2,14 +2,23 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"link-base/internal/cache"
 	"link-base/internal/config"
 	"link-base/internal/domain"
+	"link-base/internal/metrics"
 	"link-base/internal/repository"
 	"link-base/pkg/auth"
 	"link-base/pkg/hash"
+	"link-base/pkg/queue"
+	"link-base/pkg/secret"
+	"link-base/pkg/webhook"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -17,10 +26,26 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	// sessionStoreRedis selects Redis as the primary store for refresh token sessions.
+	sessionStoreRedis = "redis"
+	// maxUserAgentLength is the longest user agent stored with a session.
+	maxUserAgentLength = 512
+)
+
 type CreateUserInput struct {
-	Email      string
-	Password   string
-	ReferralId uuid.UUID
+	UserId       uuid.UUID
+	Email        string
+	Password     string
+	ReferralId   uuid.UUID
+	ReferralCode string
+	Client       ClientInfo
+}
+
+// emailChange is the pending email change stored with the confirmation token.
+type emailChange struct {
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email"`
 }
 
 type UserService struct {
@@ -28,33 +53,41 @@ type UserService struct {
 	repos        *repository.Repository
 	logger       *slog.Logger
 	cfg          config.JWTConfig
+	authCfg      config.AuthConfig
+	referralCfg  config.ReferralConfig
+	emailSender  EmailSender
 	tokenManager *auth.Manager
-	hasher       *hash.SHA1Hasher
+	hasher       hash.Hasher
 	redis        *cache.Cache
+	cipher       *secret.Cipher
+	webhook      *webhook.Client
+	webhookQueue *queue.Queue
 }
 
 // NewUserService creates a new instance of UserService.
 //
 // Parameters:
-//   - repos: A pointer to a repository.Repository instance.
-//   - logger: A pointer to a slog.Logger instance for logging.
-//   - cfg: The configuration settings for JWT tokens.
-//   - tokenManager: A pointer to an auth.Manager for managing authentication tokens.
-//   - hasher: A pointer to a hash.SHA1Hasher for password hashing.
-//   - db: A pointer to a sqlx.DB instance for database interactions.
+//   - deps: The shared service dependencies: repositories, cache, logger, database,
+//     token manager, password hasher and the JWT and auth configuration.
+//   - emailSender: The sender of password reset and verification emails.
 //
 // Returns:
 //   - *UserService: A new instance of UserService.
-func NewUserService(repos *repository.Repository, logger *slog.Logger,
-	cfg config.JWTConfig, tokenManager *auth.Manager, hasher *hash.SHA1Hasher, db *sqlx.DB, redis *cache.Cache) *UserService {
+func NewUserService(deps Deps, emailSender EmailSender) *UserService {
 	return &UserService{
-		repos:        repos,
-		logger:       logger,
-		cfg:          cfg,
-		tokenManager: tokenManager,
-		hasher:       hasher,
-		db:           db,
-		redis:        redis,
+		repos:        deps.Repos,
+		logger:       deps.Logger,
+		cfg:          deps.JWTConfig,
+		authCfg:      deps.AuthConfig,
+		referralCfg:  deps.ReferralConfig,
+		emailSender:  emailSender,
+		tokenManager: deps.TokenManager,
+		hasher:       deps.Hasher,
+		db:           deps.DB,
+		redis:        deps.Cache,
+		cipher:       deps.SecretCipher,
+		webhook:      newReferralWebhook(deps.ReferralConfig.Webhook),
+		webhookQueue: deps.WebhookQueue,
 	}
 }
 
@@ -68,82 +101,864 @@ func NewUserService(repos *repository.Repository, logger *slog.Logger,
 //
 // Returns:
 //   - Tokens: A Tokens object containing the access and refresh tokens for the
-//     newly created session.
-//   - error: An error if the authentication fails or if there is a database query
-//     failure.
-func (u *UserService) SignIn(ctx context.Context, input SignInInput) (Tokens, error) {
-	passwordHash, err := u.hasher.Hash(input.Password)
+//     newly created session, or only a two-factor challenge if the user has 2FA enabled.
+//   - error: An error if the authentication fails, domain.ErrUserNotVerified if the
+//     email hasn't been verified yet, domain.ErrUserBanned if the user is banned, domain.ErrAccountLocked if there were too many
+//     failed attempts, or if there is a database query failure.
+func (u *UserService) SignIn(ctx context.Context, input SignInInput) (tokens Tokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.SignIn")
+	defer span.End()
+
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		metrics.SignIns.WithLabelValues(result).Inc()
+	}()
+
+	input.Email = normalizeEmail(input.Email)
+	lockoutKey := u.lockoutKey(input)
+
+	locked, err := u.redis.LoginAttempts.IsLocked(ctx, lockoutKey)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if locked {
+		return Tokens{}, domain.ErrAccountLocked
+	}
+
+	user, err := u.findUserByEmail(ctx, input.Email)
 	if err != nil {
+		u.registerFailedSignIn(ctx, lockoutKey)
 		return Tokens{}, err
 	}
 
-	user, err := u.repos.User.FindByEmail(ctx, input.Email)
+	ok, err := u.hasher.Compare(user.PasswordHash, saltPassword(user.Salt, input.Password))
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	if user.PasswordHash != passwordHash {
-		return Tokens{}, fmt.Errorf("invalid credentials")
+	if !ok {
+		u.registerFailedSignIn(ctx, lockoutKey)
+		return Tokens{}, domain.ErrInvalidCredentials
+	}
+
+	if !user.IsVerified {
+		return Tokens{}, domain.ErrUserNotVerified
+	}
+
+	if user.IsBanned {
+		return Tokens{}, domain.ErrUserBanned
+	}
+
+	if err := u.redis.LoginAttempts.Reset(ctx, lockoutKey); err != nil {
+		u.logger.ErrorContext(ctx, "failed to reset failed sign ins", slog.String("reason", err.Error()))
+	}
+
+	if u.hasher.NeedsRehash(user.PasswordHash) {
+		u.rehashPassword(ctx, user, input.Password)
+	}
+
+	return u.completeSignIn(ctx, user, ClientInfo{UserAgent: input.UserAgent, IP: input.IP})
+}
+
+// lockoutKey returns the key failed sign ins are counted for: the email, combined
+// with the client IP if lockout by IP is enabled.
+func (u *UserService) lockoutKey(input SignInInput) string {
+	if u.authCfg.Lockout.ByIP && input.IP != "" {
+		return input.Email + ":" + input.IP
+	}
+
+	return input.Email
+}
+
+// registerFailedSignIn counts a failed sign in and locks the key for the cooldown
+// period once the configured number of failures within the window is reached.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - key: The lockout key of the sign in attempt.
+func (u *UserService) registerFailedSignIn(ctx context.Context, key string) {
+	failures, err := u.redis.LoginAttempts.Increment(ctx, key, u.authCfg.Lockout.Window)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to count failed sign in", slog.String("reason", err.Error()))
+		return
+	}
+
+	if failures < u.authCfg.Lockout.MaxAttempts {
+		return
+	}
+
+	if err := u.redis.LoginAttempts.Lock(ctx, key, u.authCfg.Lockout.Cooldown); err != nil {
+		u.logger.ErrorContext(ctx, "failed to lock sign in", slog.String("reason", err.Error()))
+	}
+}
+
+// rehashPassword recomputes the password hash with the current hasher and stores it.
+//
+// It is called after a successful credential check when the stored hash uses an outdated
+// scheme. Failures are logged and never fail the sign in.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - user: The authenticated user.
+//   - password: The verified plain text password.
+func (u *UserService) rehashPassword(ctx context.Context, user domain.User, password string) {
+	passwordHash, err := u.hasher.Hash(saltPassword(user.Salt, password))
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to rehash password", slog.String("reason", err.Error()))
+		return
+	}
+
+	if err := u.repos.User.UpdatePasswordHash(ctx, user.UserId, passwordHash); err != nil {
+		u.logger.ErrorContext(ctx, "failed to store rehashed password", slog.String("reason", err.Error()))
+		return
 	}
 
-	return u.createSession(ctx, user.UserId)
+	u.forgetUser(ctx, user.Email)
 }
 
 // SignUp registers a new user with the provided credentials and returns a new session.
 //
+// An unknown or expired referral code fails the sign up in strict mode and is ignored
+// otherwise.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - input: The SignUpInput containing the email, password, and referral code for the user to be registered.
 //
 // Returns:
 //   - Tokens: A Tokens object containing the access and refresh tokens for the newly created session.
-//   - error: An error if registration fails or if there is a database query failure.
+//   - error: A *domain.PasswordPolicyError if the password is too weak,
+//     domain.ErrReferralCodeNotFound if the referral code is unknown in strict mode, an
+//     error if registration fails or if there is a database query failure.
 func (u *UserService) SignUp(ctx context.Context, input SignUpInput) (Tokens, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SignUp")
+	defer span.End()
+
+	if err := checkPasswordPolicy(u.authCfg.PasswordPolicy, input.Password); err != nil {
+		return Tokens{}, err
+	}
+
 	referralId := uuid.Nil
 	if input.ReferralCode != "" {
 		var err error
-		referralId, err = u.redis.Referral.FindByReferralCode(ctx, input.ReferralCode)
-		if err != nil {
+		referralId, err = u.findReferrer(ctx, input.ReferralCode)
+		switch {
+		case errors.Is(err, domain.ErrReferralCodeNotFound) && !u.referralCfg.StrictSignUp:
+			u.logger.InfoContext(ctx, "unknown referral code, signing up without referral",
+				slog.String("code", input.ReferralCode))
+			input.ReferralCode = ""
+		case err != nil:
 			return Tokens{}, fmt.Errorf("failed to find referral code: %w", err)
 		}
 	}
 
 	return u.createUser(ctx, CreateUserInput{
-		Email:      input.Email,
-		Password:   input.Password,
-		ReferralId: referralId,
+		UserId:       uuid.New(),
+		Email:        normalizeEmail(input.Email),
+		Password:     input.Password,
+		ReferralId:   referralId,
+		ReferralCode: input.ReferralCode,
+		Client:       ClientInfo{UserAgent: input.UserAgent, IP: input.IP},
 	})
 }
 
+// findReferrer returns the owner of an active referral code from the referral code cache,
+// falling back to the database for codes that outlive their cache entry.
+func (u *UserService) findReferrer(ctx context.Context, code string) (uuid.UUID, error) {
+	owner, err := u.redis.Referral.FindByReferralCode(ctx, code)
+	if !errors.Is(err, domain.ErrReferralCodeNotFound) {
+		return owner, err
+	}
+
+	return u.repos.Referral.FindCodeOwner(ctx, code)
+}
+
 // RefreshTokens generates a new set of tokens using the provided refresh token.
 //
+// The refresh token is rotated: it is consumed before the new session is created, so it
+// can't be used twice, not even by concurrent requests.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - refreshToken: The refresh token used to generate new session tokens.
+//   - client: The client the new session is created for.
 //
 // Returns:
 //   - Tokens: A new set of access and refresh tokens.
-//   - error: An error if the refresh token is invalid or if there is a database query failure.
-func (u *UserService) RefreshTokens(ctx context.Context, refreshToken string) (Tokens, error) {
-	session, err := u.repos.RefreshToken.FindByRefreshToken(ctx, refreshToken)
+//   - error: domain.ErrSessionNotFound if the refresh token is unknown, expired or has
+//     already been used, or an error if there is a database query failure.
+func (u *UserService) RefreshTokens(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error) {
+	ctx, span := tracer.Start(ctx, "UserService.RefreshTokens")
+	defer span.End()
+
+	userID, err := u.consumeSession(ctx, refreshToken)
 	if err != nil {
-		return Tokens{}, fmt.Errorf("failed to find refresh token: %w", err)
+		return Tokens{}, err
+	}
+
+	return u.createSession(ctx, userID, client)
+}
+
+// consumeSession ends the session of the refresh token and returns the user it belonged to.
+//
+// With the Redis session store the session is consumed from Redis first, which saves the
+// lookup in Postgres. The Postgres row is still deleted, and Postgres stays the source of
+// truth: a refresh token it no longer holds, e.g. after a logout from all devices, is
+// rejected. If Redis doesn't hold the session, it is consumed from Postgres.
+func (u *UserService) consumeSession(ctx context.Context, refreshToken string) (uuid.UUID, error) {
+	if u.authCfg.SessionStore == sessionStoreRedis {
+		userID, err := u.redis.Session.Consume(ctx, refreshToken)
+		if err == nil {
+			if err := u.repos.RefreshToken.DeleteByRefreshToken(ctx, refreshToken); err != nil {
+				return uuid.Nil, fmt.Errorf("failed to delete refresh token: %w", err)
+			}
+
+			return userID, nil
+		}
+
+		if !errors.Is(err, domain.ErrSessionNotFound) {
+			u.logger.ErrorContext(ctx, "failed to get session from cache", slog.String("reason", err.Error()))
+		}
+	}
+
+	userID, err := u.repos.RefreshToken.Consume(ctx, refreshToken)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// deleteCachedSessions deletes the sessions of the user from the Redis session store,
+// except the one with the given refresh token.
+func (u *UserService) deleteCachedSessions(ctx context.Context, userID uuid.UUID, keep string) error {
+	if u.authCfg.SessionStore != sessionStoreRedis {
+		return nil
 	}
 
-	return u.createSession(ctx, session.UserID)
+	return u.redis.Session.DeleteByUserID(ctx, userID, keep)
+}
+
+// RevokeToken blacklists the access token with the given ID for the rest of its lifetime.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - jti: The ID of the access token to be revoked.
+//   - ttl: The remaining lifetime of the access token.
+//
+// Returns:
+//   - error: An error if the token ID can't be stored in the blacklist.
+func (u *UserService) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	return u.redis.Blacklist.Revoke(ctx, jti, ttl)
+}
+
+// IsTokenRevoked reports whether the access token with the given ID has been revoked.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - jti: The ID of the access token to check.
+//
+// Returns:
+//   - bool: True if the token has been revoked.
+//   - error: An error if the blacklist can't be queried.
+func (u *UserService) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return u.redis.Blacklist.IsRevoked(ctx, jti)
+}
+
+// Logout ends all sessions of the user by deleting every refresh token and
+// revoking the access token used for the current request.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user to be logged out.
+//   - jti: The ID of the access token used for the current request.
+//   - ttl: The remaining lifetime of the access token.
+//
+// Returns:
+//   - error: An error if the refresh tokens can't be deleted or the access token can't be revoked.
+func (u *UserService) Logout(ctx context.Context, userID uuid.UUID, jti string, ttl time.Duration) error {
+	if err := u.repos.RefreshToken.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := u.deleteCachedSessions(ctx, userID, ""); err != nil {
+		return err
+	}
+
+	if jti == "" {
+		return nil
+	}
+
+	return u.RevokeToken(ctx, jti, ttl)
+}
+
+// LogoutOthers ends every session of the user except the one identified by the
+// current refresh token.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose other sessions are to be ended.
+//   - currentRefreshToken: The refresh token of the session to be kept.
+//
+// Returns:
+//   - error: domain.ErrSessionNotFound if the refresh token is unknown or doesn't belong to
+//     the user, or an error if the refresh tokens can't be looked up or deleted.
+func (u *UserService) LogoutOthers(ctx context.Context, userID uuid.UUID, currentRefreshToken string) error {
+	session, err := u.repos.RefreshToken.FindByRefreshToken(ctx, currentRefreshToken)
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != userID {
+		return domain.ErrSessionNotFound
+	}
+
+	if err := u.repos.RefreshToken.DeleteOthersByUserID(ctx, userID, currentRefreshToken); err != nil {
+		return err
+	}
+
+	return u.deleteCachedSessions(ctx, userID, currentRefreshToken)
+}
+
+// ListSessions returns the active sessions (non-expired refresh tokens) of the user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose sessions are to be listed.
+//
+// Returns:
+//   - []domain.RefreshToken: The active sessions of the user.
+//   - error: An error if there is a database query failure.
+func (u *UserService) ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.RefreshToken, error) {
+	return u.repos.RefreshToken.FindActiveByUserID(ctx, userID)
+}
+
+// RequestMagicLink emails a single-use sign in link to the user with the given email.
+//
+// The token is stored in Redis for the configured TTL and mapped to the user ID. Requesting
+// a link for an unknown email is not an error, so the caller can't enumerate accounts.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The email address of the user who wants to sign in.
+//
+// Returns:
+//   - error: An error if the token can't be generated or stored.
+func (u *UserService) RequestMagicLink(ctx context.Context, email string) error {
+	user, err := u.findUserByEmail(ctx, normalizeEmail(email))
+	if err != nil {
+		u.logger.InfoContext(ctx, "magic link requested for unknown email")
+		return nil
+	}
+
+	token, err := u.tokenManager.NewRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.redis.MagicLink.Create(ctx, token, user.UserId.String(), u.authCfg.MagicLinkTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello!\n\nSign in by opening this link: %s?token=%s\n\nIt is valid for %s.\n\nBest regards!",
+		u.authCfg.MagicLinkURL, token, u.authCfg.MagicLinkTTL)
+	if err := u.emailSender.Send(ctx, user.Email, "Your Sign In Link", body); err != nil {
+		u.logger.ErrorContext(ctx, "failed to send magic link email", slog.String("reason", err.Error()))
+	}
+
+	return nil
+}
+
+// SignInWithMagicLink consumes a magic link token and signs the user in.
+//
+// Opening the link proves the user owns the email address, so an unverified account
+// is marked as verified.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The token from the magic link.
+//   - client: The client the new session is created for.
+//
+// Returns:
+//   - Tokens: The access and refresh tokens of the new session, or only a two-factor
+//     challenge if the user has 2FA enabled.
+//   - error: domain.ErrTokenNotFound if the token is unknown, expired or already used, or
+//     an error if the session can't be created.
+func (u *UserService) SignInWithMagicLink(ctx context.Context, token string, client ClientInfo) (Tokens, error) {
+	value, err := u.redis.MagicLink.Consume(ctx, token)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("invalid user ID in magic link token: %w", err)
+	}
+
+	// The link can be opened right after sign up, before the replica has the user.
+	user, err := u.repos.User.FindByUserIdPrimary(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if !user.IsVerified {
+		if err := u.repos.User.SetVerified(ctx, user.UserId); err != nil {
+			return Tokens{}, err
+		}
+
+		u.forgetUser(ctx, user.Email)
+	}
+
+	return u.completeSignIn(ctx, user, client)
+}
+
+// RequestPasswordReset emails a single-use password reset token to the user with the given email.
+//
+// The token is stored in Redis for the configured TTL and mapped to the user ID. Requesting
+// a reset for an unknown email is not an error, so the caller can't enumerate accounts.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The email address of the user who forgot the password.
+//
+// Returns:
+//   - error: An error if the token can't be generated or stored.
+func (u *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := u.repos.User.FindByEmail(ctx, normalizeEmail(email))
+	if err != nil {
+		u.logger.InfoContext(ctx, "password reset requested for unknown email")
+		return nil
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.redis.PasswordReset.Create(ctx, token, user.UserId.String(), u.authCfg.PasswordResetTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello!\n\nYour password reset token is: %s\n\nIt is valid for %s.\n\nBest regards!",
+		token, u.authCfg.PasswordResetTTL)
+	if err := u.emailSender.Send(ctx, user.Email, "Password Reset", body); err != nil {
+		u.logger.ErrorContext(ctx, "failed to send password reset email", slog.String("reason", err.Error()))
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset sets a new password for the user the reset token was issued to.
+//
+// The token is consumed, so it can't be used twice, and all refresh tokens of the
+// user are deleted so existing sessions have to sign in again.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The password reset token.
+//   - newPassword: The new password.
+//
+// Returns:
+//   - error: domain.ErrTokenNotFound if the token is unknown or expired, or an error
+//     if the password can't be updated.
+func (u *UserService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	value, err := u.redis.PasswordReset.Consume(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid user ID in password reset token: %w", err)
+	}
+
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := u.hasher.Hash(saltPassword(user.Salt, newPassword))
+	if err != nil {
+		return err
+	}
+
+	if err := u.repos.User.UpdatePasswordHash(ctx, user.UserId, passwordHash); err != nil {
+		return err
+	}
+
+	u.forgetUser(ctx, user.Email)
+
+	if err := u.repos.RefreshToken.DeleteByUserID(ctx, user.UserId); err != nil {
+		return err
+	}
+
+	return u.deleteCachedSessions(ctx, user.UserId, "")
+}
+
+// VerifyEmail marks the email of the user the verification token was issued to as verified.
+// The token is consumed, so it can't be used twice.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The email verification token.
+//
+// Returns:
+//   - error: domain.ErrTokenNotFound if the token is unknown or expired, or an error
+//     if the user can't be updated.
+func (u *UserService) VerifyEmail(ctx context.Context, token string) error {
+	value, err := u.redis.Verification.Consume(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid user ID in verification token: %w", err)
+	}
+
+	if err := u.repos.User.SetVerified(ctx, userID); err != nil {
+		return err
+	}
+
+	u.forgetUserByID(ctx, userID)
+
+	return nil
+}
+
+// ResendVerification sends a new verification email to a still unverified account.
+//
+// Unknown and already verified emails are not an error, so the caller can't enumerate accounts.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The email address of the account.
+//
+// Returns:
+//   - error: An error if the verification token can't be generated or stored.
+func (u *UserService) ResendVerification(ctx context.Context, email string) error {
+	user, err := u.repos.User.FindByEmail(ctx, normalizeEmail(email))
+	if err != nil || user.IsVerified {
+		return nil
+	}
+
+	if err := u.sendVerification(ctx, user); err != nil {
+		u.logger.ErrorContext(ctx, "failed to send verification email", slog.String("reason", err.Error()))
+	}
+
+	return nil
+}
+
+// sendVerification stores a new single-use verification token and emails the verification link.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - user: The user whose email is to be verified.
+//
+// Returns:
+//   - error: An error if the token can't be generated or stored, or the email can't be sent.
+func (u *UserService) sendVerification(ctx context.Context, user domain.User) error {
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.redis.Verification.Create(ctx, token, user.UserId.String(), u.authCfg.VerificationTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello!\n\nPlease verify your email by opening this link: %s?token=%s\n\nBest regards!",
+		u.authCfg.VerificationURL, token)
+
+	return u.emailSender.Send(ctx, user.Email, "Verify Your Email", body)
+}
+
+// ChangePassword replaces the password of the user after verifying the old one.
+//
+// All refresh tokens of the user are deleted afterwards, so other sessions are logged out.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose password is to be changed.
+//   - oldPassword: The current password.
+//   - newPassword: The new password.
+//
+// Returns:
+//   - error: domain.ErrInvalidCredentials if the old password is wrong, or an error
+//     if the password can't be updated.
+func (u *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := u.hasher.Compare(user.PasswordHash, saltPassword(user.Salt, oldPassword))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return domain.ErrInvalidCredentials
+	}
+
+	passwordHash, err := u.hasher.Hash(saltPassword(user.Salt, newPassword))
+	if err != nil {
+		return err
+	}
+
+	if err := u.repos.User.UpdatePasswordHash(ctx, user.UserId, passwordHash); err != nil {
+		return err
+	}
+
+	u.forgetUser(ctx, user.Email)
+
+	if err := u.repos.RefreshToken.DeleteByUserID(ctx, user.UserId); err != nil {
+		return err
+	}
+
+	return u.deleteCachedSessions(ctx, user.UserId, "")
+}
+
+// RequestEmailChange stores the pending email change and emails a confirmation link to the new address.
+//
+// The current email stays active until the change is confirmed.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user whose email is to be changed.
+//   - newEmail: The new email address.
+//
+// Returns:
+//   - error: domain.ErrEmailInUse if the new email belongs to another account, or an error
+//     if the confirmation token can't be stored or the email can't be sent.
+func (u *UserService) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) error {
+	newEmail = normalizeEmail(newEmail)
+	if _, err := u.repos.User.FindByEmail(ctx, newEmail); err == nil {
+		return domain.ErrEmailInUse
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(emailChange{UserID: userID, Email: newEmail})
+	if err != nil {
+		return err
+	}
+
+	if err := u.redis.EmailChange.Create(ctx, token, string(value), u.authCfg.EmailChangeTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello!\n\nPlease confirm your new email by opening this link: %s?token=%s\n\nBest regards!",
+		u.authCfg.EmailConfirmURL, token)
+
+	return u.emailSender.Send(ctx, newEmail, "Confirm Your New Email", body)
+}
+
+// ConfirmEmailChange applies the pending email change the confirmation token was issued for.
+// The token is consumed, so it can't be used twice.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - token: The email change confirmation token.
+//
+// Returns:
+//   - error: domain.ErrTokenNotFound if the token is unknown or expired, domain.ErrEmailInUse
+//     if the email has been taken in the meantime, or an error if the user can't be updated.
+func (u *UserService) ConfirmEmailChange(ctx context.Context, token string) error {
+	value, err := u.redis.EmailChange.Consume(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	var change emailChange
+	if err := json.Unmarshal([]byte(value), &change); err != nil {
+		return fmt.Errorf("invalid email change token value: %w", err)
+	}
+
+	if _, err := u.repos.User.FindByEmailPrimary(ctx, change.Email); err == nil {
+		return domain.ErrEmailInUse
+	}
+
+	user, err := u.repos.User.FindByUserIdPrimary(ctx, change.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.repos.User.UpdateEmail(ctx, change.UserID, change.Email); err != nil {
+		return err
+	}
+
+	// The old email is forgotten only after the update, so a concurrent sign in can't
+	// cache the old record again.
+	u.forgetUser(ctx, user.Email)
+
+	return nil
+}
+
+// DeleteAccount deactivates the user and removes their refresh tokens and referral codes
+// in a single transaction, then drops the cached referral codes.
+//
+// The users row and the referral edges are kept for audit; the deactivated user is treated
+// as absent by sign in and sign up, so the email can be reused.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user to be deactivated.
+//
+// Returns:
+//   - error: An error if any of the updates fails; nothing is changed in that case.
+func (u *UserService) DeleteAccount(ctx context.Context, userID uuid.UUID) error {
+	codes, err := u.repos.Referral.FindCodeByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	u.forgetUserByID(ctx, userID)
+
+	err = repository.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repos.RefreshToken.DeleteByUserIDTx(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		if err := u.repos.Referral.DeleteCodesByUserID(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return u.repos.User.Deactivate(ctx, tx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.deleteCachedSessions(ctx, userID, ""); err != nil {
+		u.logger.ErrorContext(ctx, "failed to delete cached sessions", slog.String("reason", err.Error()))
+	}
+
+	for _, code := range codes {
+		if err := u.redis.Referral.Delete(ctx, code.ReferralCode); err != nil {
+			u.logger.ErrorContext(ctx, "failed to delete cached referral code", slog.String("reason", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// GetProfile returns the user with the given ID.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//
+// Returns:
+//   - domain.User: The user details.
+//   - error: An error if the user is not found or if there is a database query failure.
+func (u *UserService) GetProfile(ctx context.Context, userID uuid.UUID) (domain.User, error) {
+	return u.repos.User.FindByUserId(ctx, userID)
+}
+
+// GetPoints returns the referral reward balance of the user.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//
+// Returns:
+//   - int64: The number of points.
+//   - error: An error if the user is not found or if there is a database query failure.
+func (u *UserService) GetPoints(ctx context.Context, userID uuid.UUID) (int64, error) {
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return user.Points, nil
+}
+
+// ExportData collects everything stored about the user: the profile, referral codes,
+// referred users, active sessions and API keys.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - userID: The UUID of the user.
+//
+// Returns:
+//   - UserExport: The user's data. Callers must leave out secrets such as the password hash.
+//   - error: An error if the user is not found or if there is a database query failure.
+func (u *UserService) ExportData(ctx context.Context, userID uuid.UUID) (UserExport, error) {
+	user, err := u.repos.User.FindByUserId(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	codes, err := u.repos.Referral.FindCodeByUserID(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	count, err := u.repos.Referral.CountReferred(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	referred := []uuid.UUID{}
+	if count > 0 {
+		if referred, err = u.repos.Referral.FindReferralByUserID(ctx, userID, count, 0); err != nil {
+			return UserExport{}, err
+		}
+	}
+
+	sessions, err := u.repos.RefreshToken.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	apiKeys, err := u.repos.APIKey.FindByUserID(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	return UserExport{
+		User:          user,
+		ReferralCodes: codes,
+		Referred:      referred,
+		Sessions:      sessions,
+		APIKeys:       apiKeys,
+	}, nil
 }
 
 // createSession creates a new session for the given user ID and returns the session tokens.
+// The user's roles are looked up and embedded in the access token, and the client's user
+// agent and IP are stored with the refresh token so the sessions list can tell devices apart.
+// If the user is at the configured session limit, the oldest sessions are ended first.
+// Banned users get no new sessions, which also stops them from refreshing tokens.
 //
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
 //   - userID: The UUID of the user for whom the session is to be created.
+//   - client: The client the session is created for.
 //
 // Returns:
 //   - Tokens: The session tokens containing the access token and refresh token.
-//   - error: An error if the session could not be created or if there is a database query failure.
-func (u *UserService) createSession(ctx context.Context, userID uuid.UUID) (Tokens, error) {
-	accessToken, err := u.tokenManager.NewJWT(userID.String(), u.cfg.AccessTokenTTL)
+//   - error: domain.ErrUserBanned if the user is banned, or an error if the session could
+//     not be created or if there is a database query failure.
+func (u *UserService) createSession(ctx context.Context, userID uuid.UUID, client ClientInfo) (Tokens, error) {
+	// Sessions are created right after sign ups and bans are written, so the replica may lag.
+	user, err := u.repos.User.FindByUserIdPrimary(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if user.IsBanned {
+		return Tokens{}, domain.ErrUserBanned
+	}
+
+	accessToken, err := u.tokenManager.NewJWT(userID.String(), []string{user.Role}, u.cfg.AccessTokenTTL)
 	if err != nil {
 		return Tokens{}, err
 	}
@@ -153,9 +968,13 @@ func (u *UserService) createSession(ctx context.Context, userID uuid.UUID) (Toke
 		return Tokens{}, err
 	}
 
+	u.evictOldestSessions(ctx, userID)
+
 	session := domain.RefreshToken{
 		UserID:       userID,
 		RefreshToken: refreshToken,
+		UserAgent:    truncate(client.UserAgent, maxUserAgentLength),
+		IP:           client.IP,
 		ExpiresAt:    time.Now().Add(u.cfg.RefreshTokenTTL),
 	}
 
@@ -163,70 +982,298 @@ func (u *UserService) createSession(ctx context.Context, userID uuid.UUID) (Toke
 		return Tokens{}, err
 	}
 
+	if u.authCfg.SessionStore == sessionStoreRedis {
+		if err := u.redis.Session.Create(ctx, refreshToken, userID, u.cfg.RefreshTokenTTL); err != nil {
+			u.logger.ErrorContext(ctx, "failed to cache session", slog.String("reason", err.Error()))
+		}
+	}
+
 	return Tokens{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
 
+// evictOldestSessions deletes the oldest sessions of the user so a new one fits within
+// the configured maximum. Failures are logged rather than blocking the sign in.
+func (u *UserService) evictOldestSessions(ctx context.Context, userID uuid.UUID) {
+	if u.authCfg.MaxSessions <= 0 {
+		return
+	}
+
+	sessions, err := u.repos.RefreshToken.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to count sessions", slog.String("reason", err.Error()))
+		return
+	}
+
+	excess := len(sessions) - u.authCfg.MaxSessions + 1
+	if excess <= 0 {
+		return
+	}
+
+	evicted, err := u.repos.RefreshToken.DeleteOldestByUserID(ctx, userID, excess)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to evict oldest sessions", slog.String("reason", err.Error()))
+		return
+	}
+
+	if u.authCfg.SessionStore == sessionStoreRedis {
+		for _, refreshToken := range evicted {
+			if _, err := u.redis.Session.Consume(ctx, refreshToken); err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+				u.logger.ErrorContext(ctx, "failed to delete cached session", slog.String("reason", err.Error()))
+			}
+		}
+	}
+
+	u.logger.InfoContext(ctx, "evicted oldest sessions", slog.Int("count", len(evicted)))
+}
+
 // createUser registers a new user with the provided email and password and returns a new session.
 //
+// The referral is only linked if the code still has uses left and doesn't belong to the
+// new user. Referrals flagged by the sign ups per IP check are recorded but neither
+// rewarded nor reported to the referral webhook. The referrer is credited the configured
+// reward points in the same transaction, so a referral is never credited twice or without
+// being recorded.
+//
 // Parameters:
 //   - ctx: The context for controlling the request lifecycle.
-//   - input: The createUserInput containing the email, password, and referral ID for the user to be registered.
+//   - input: The createUserInput containing the ID, email, password, and referral ID and code for the user to be registered.
 //
 // Returns:
 //   - Tokens: The session tokens containing the access token and refresh token.
-//   - error: An error if the session could not be created or if there is a database query failure.
+//   - error: domain.ErrEmailInUse if the email belongs to another user,
+//     domain.ErrReferralSuspicious if the referrer has too many sign ups from the IP and
+//     such sign ups are rejected, or an error if the session could not be created or if
+//     there is a database query failure.
 func (u *UserService) createUser(ctx context.Context, input CreateUserInput) (Tokens, error) {
-	_, err := u.repos.User.FindByEmail(ctx, input.Email)
-	if err == nil {
-		return Tokens{}, fmt.Errorf("email already in use")
+	ctx, span := tracer.Start(ctx, "UserService.createUser")
+	defer span.End()
+
+	// The lookup only fails fast on the common case; the insert below is what guarantees
+	// uniqueness when sign ups race.
+	if _, err := u.findUserByEmail(ctx, input.Email); err == nil {
+		return Tokens{}, domain.ErrEmailInUse
 	}
 
-	passwordHash, err := u.hasher.Hash(input.Password)
+	salt, err := generateSalt()
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	tx, err := u.db.BeginTxx(ctx, nil)
+	passwordHash, err := u.hasher.Hash(saltPassword(salt, input.Password))
 	if err != nil {
 		return Tokens{}, err
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
-		}
-	}()
 
 	user := domain.User{
-		UserId:       uuid.New(),
+		UserId:       input.UserId,
 		Email:        input.Email,
 		PasswordHash: passwordHash,
+		Salt:         salt,
+		Role:         domain.RoleUser,
 	}
 
-	if err = u.repos.User.Create(ctx, tx, user); err != nil {
-		return Tokens{}, err
+	var flagged bool
+	if input.ReferralId != uuid.Nil {
+		flagged, err = u.checkReferralIP(ctx, input.ReferralId, input.Client.IP)
+		if err != nil {
+			return Tokens{}, err
+		}
 	}
 
-	if input.ReferralId != uuid.Nil {
-		if err = u.repos.Referral.CreateReferral(ctx, tx, domain.ReferralUser{
+	var referral *domain.ReferralUser
+	err = repository.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repos.User.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if input.ReferralId == uuid.Nil {
+			return nil
+		}
+
+		// The caller picks the ID of the new user, so nothing here stops a code from
+		// resolving to it; a user is never linked to or rewarded for their own sign up.
+		if input.ReferralId == user.UserId {
+			u.logger.WarnContext(ctx, "referral code resolves to the new user, skipping referral", slog.String("code", input.ReferralCode))
+			return nil
+		}
+
+		counted, err := u.repos.Referral.IncrementUses(ctx, tx, input.ReferralCode)
+		if err != nil {
+			return err
+		}
+
+		if !counted {
+			u.logger.InfoContext(ctx, "referral code is used up, skipping referral", slog.String("code", input.ReferralCode))
+			return nil
+		}
+
+		edge := domain.ReferralUser{
 			UserID:   user.UserId,
 			Referral: input.ReferralId,
-		}); err != nil {
-			return Tokens{}, err
+			Code:     input.ReferralCode,
+			IP:       input.Client.IP,
+			Flagged:  flagged,
+		}
+		created, err := u.repos.Referral.CreateReferral(ctx, tx, edge)
+		if err != nil {
+			return err
+		}
+
+		if !created {
+			u.logger.WarnContext(ctx, "user already has a referral, skipping reward", slog.String("code", input.ReferralCode))
+			return nil
+		}
+		referral = &edge
+
+		if flagged || u.referralCfg.RewardPoints == 0 {
+			return nil
 		}
-	}
 
-	err = tx.Commit()
+		return u.repos.User.AddPoints(ctx, tx, input.ReferralId, u.referralCfg.RewardPoints)
+	})
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	u.logger.Info("Create user")
-	return u.createSession(ctx, user.UserId)
+	u.logger.InfoContext(ctx, "Create user")
+
+	if referral != nil && !referral.Flagged {
+		u.notifyReferral(ctx, *referral)
+	}
+
+	if err := u.sendVerification(ctx, user); err != nil {
+		u.logger.ErrorContext(ctx, "failed to send verification email", slog.String("reason", err.Error()))
+	}
+
+	return u.createSession(ctx, user.UserId, input.Client)
+}
+
+// checkReferralIP counts a referred sign up from the IP and reports whether the referrer
+// has exceeded the configured number of sign ups from it within the window.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - referrerID: The UUID of the owner of the referral code.
+//   - ip: The client IP of the sign up.
+//
+// Returns:
+//   - bool: True if the referral should be flagged.
+//   - error: domain.ErrReferralSuspicious if the threshold is exceeded and such sign ups
+//     are rejected, or an error if the counter can't be updated.
+func (u *UserService) checkReferralIP(ctx context.Context, referrerID uuid.UUID, ip string) (bool, error) {
+	fraud := u.referralCfg.Fraud
+	if !fraud.Enabled || ip == "" {
+		return false, nil
+	}
+
+	count, err := u.redis.ReferralIPs.Increment(ctx, referrerID.String()+":"+ip, fraud.Window)
+	if err != nil {
+		return false, err
+	}
+
+	if count <= int64(fraud.MaxSignUpsPerIP) {
+		return false, nil
+	}
+
+	u.logger.WarnContext(ctx, "too many referred sign ups from one IP",
+		slog.String("referrer", referrerID.String()),
+		slog.String("ip", ip),
+		slog.Int64("count", count),
+		slog.Bool("rejected", fraud.Reject))
+
+	if fraud.Reject {
+		return false, domain.ErrReferralSuspicious
+	}
+
+	return true, nil
+}
+
+// findUserByEmail returns the user with the given email from the user cache, falling back
+// to the database and caching the result.
+//
+// Only found users are cached, so a miss never blocks a later sign up with that email.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - email: The normalized email of the user.
+//
+// Returns:
+//   - domain.User: The user with the given email.
+//   - error: An error if the user is not found or if there is a database query failure.
+func (u *UserService) findUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	user, ok, err := u.redis.User.Get(ctx, email)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to get cached user", slog.String("reason", err.Error()))
+	}
+
+	if ok {
+		return user, nil
+	}
+
+	user, err = u.repos.User.FindByEmail(ctx, email)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if err := u.redis.User.Set(ctx, user); err != nil {
+		u.logger.ErrorContext(ctx, "failed to cache user", slog.String("reason", err.Error()))
+	}
+
+	return user, nil
+}
+
+// forgetUser drops the cached user with the given email after the user was changed.
+func (u *UserService) forgetUser(ctx context.Context, email string) {
+	if err := u.redis.User.Delete(ctx, email); err != nil {
+		u.logger.ErrorContext(ctx, "failed to delete cached user", slog.String("reason", err.Error()))
+	}
+}
+
+// forgetUserByID drops the cached user with the given ID after the user was changed.
+func (u *UserService) forgetUserByID(ctx context.Context, userID uuid.UUID) {
+	user, err := u.repos.User.FindByUserIdPrimary(ctx, userID)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to find user to forget", slog.String("reason", err.Error()))
+		return
+	}
+
+	u.forgetUser(ctx, user.Email)
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// normalizeEmail trims and lowercases the email, so addresses differing only in case
+// belong to the same account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// generateSalt generates a cryptographically random per-user password salt.
+//
+// Returns:
+//   - string: The hex encoded salt.
+//   - error: An error if the random number generator fails.
+func generateSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// saltPassword combines the per-user salt with the password before it is hashed.
+// Users created before per-user salts have an empty salt, so their hashes keep verifying.
+func saltPassword(salt, password string) string {
+	return salt + password
 }
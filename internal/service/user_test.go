@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"link-base/internal/domain"
+	"link-base/pkg/hash"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// addUser stores a verified user without a per-user salt whose password is hashed by
+// the given hasher.
+func addUser(t *testing.T, env *testEnv, hasher hash.Hasher, email, password string) domain.User {
+	t.Helper()
+
+	passwordHash, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+
+	user := domain.User{
+		UserId:       uuid.New(),
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         domain.RoleUser,
+		IsVerified:   true,
+	}
+	env.users.add(user)
+
+	return user
+}
+
+func TestUserService_SignIn_RehashesLegacyPassword(t *testing.T) {
+	svc, env := newTestUserService(t)
+	user := addUser(t, env, hash.NewSHA1Hasher(testSalt), "user@example.com", "Password1!")
+
+	if _, err := svc.SignIn(context.Background(), SignInInput{Email: user.Email, Password: "Password1!"}); err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+
+	stored := env.users.get(user.UserId).PasswordHash
+	if !strings.HasPrefix(stored, "$2") {
+		t.Fatalf("stored hash = %q, want a bcrypt hash", stored)
+	}
+
+	ok, err := hash.NewBcryptHasher(4).Compare(stored, "Password1!")
+	if err != nil || !ok {
+		t.Fatalf("Compare(rehashed) = %v, %v; want true, nil", ok, err)
+	}
+
+	if env.userCache.has(user.Email) {
+		t.Error("user with the outdated hash is still cached")
+	}
+
+	if _, err := svc.SignIn(context.Background(), SignInInput{Email: user.Email, Password: "Password1!"}); err != nil {
+		t.Fatalf("SignIn() with the rehashed password error = %v", err)
+	}
+}
+
+func TestUserService_SignIn_UpdatesLastLogin(t *testing.T) {
+	svc, env := newTestUserService(t)
+	user := addUser(t, env, hash.NewSHA1Hasher(testSalt), "user@example.com", "Password1!")
+
+	var previous time.Time
+	for i := range 2 {
+		if _, err := svc.SignIn(context.Background(), SignInInput{Email: user.Email, Password: "Password1!"}); err != nil {
+			t.Fatalf("SignIn() error = %v", err)
+		}
+
+		lastLogin := env.users.get(user.UserId).LastLoginAt
+		if lastLogin == nil || !lastLogin.After(previous) {
+			t.Fatalf("login %d: last login = %v, want after %v", i+1, lastLogin, previous)
+		}
+		previous = *lastLogin
+	}
+
+	if _, err := svc.SignIn(context.Background(), SignInInput{Email: user.Email, Password: "wrong"}); err == nil {
+		t.Fatal("SignIn() with a wrong password succeeded")
+	}
+
+	if env.users.lastLoginUpdates != 2 {
+		t.Errorf("last login updates = %d, want 2", env.users.lastLoginUpdates)
+	}
+}
+
+func TestUserService_EmailIsCaseInsensitive(t *testing.T) {
+	svc, env := newTestUserService(t)
+	ctx := context.Background()
+
+	if _, err := svc.SignUp(ctx, SignUpInput{Email: " User@Example.COM ", Password: "Password1!"}); err != nil {
+		t.Fatalf("SignUp() error = %v", err)
+	}
+
+	user, err := env.users.FindByEmail(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("stored email is not normalized: %v", err)
+	}
+	user.IsVerified = true
+	env.users.add(user)
+
+	if _, err := svc.SignIn(ctx, SignInInput{Email: "user@example.com", Password: "Password1!"}); err != nil {
+		t.Fatalf("SignIn() with the lowercase email error = %v", err)
+	}
+
+	_, err = svc.SignUp(ctx, SignUpInput{Email: "USER@example.com", Password: "Password1!"})
+	if !errors.Is(err, domain.ErrEmailInUse) {
+		t.Fatalf("SignUp() with a case variant error = %v, want %v", err, domain.ErrEmailInUse)
+	}
+}
+
+func TestUserService_SignIn_UsesCachedUser(t *testing.T) {
+	svc, env := newTestUserService(t)
+	user := addUser(t, env, hash.NewBcryptHasher(4), "user@example.com", "Password1!")
+
+	for range 2 {
+		if _, err := svc.SignIn(context.Background(), SignInInput{Email: user.Email, Password: "Password1!"}); err != nil {
+			t.Fatalf("SignIn() error = %v", err)
+		}
+	}
+
+	if env.users.findByEmailCalls != 1 {
+		t.Errorf("database lookups = %d, want 1", env.users.findByEmailCalls)
+	}
+}
+
+func TestUserService_ChangePassword_InvalidatesCachedUser(t *testing.T) {
+	svc, env := newTestUserService(t)
+	ctx := context.Background()
+	user := addUser(t, env, hash.NewBcryptHasher(4), "user@example.com", "Password1!")
+
+	if _, err := svc.SignIn(ctx, SignInInput{Email: user.Email, Password: "Password1!"}); err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+
+	if !env.userCache.has(user.Email) {
+		t.Fatal("user is not cached after sign in")
+	}
+
+	if err := svc.ChangePassword(ctx, user.UserId, "Password1!", "Password2!"); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	if env.userCache.has(user.Email) {
+		t.Fatal("user is still cached after the password change")
+	}
+
+	_, err := svc.SignIn(ctx, SignInInput{Email: user.Email, Password: "Password1!"})
+	if !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("SignIn() with the old password error = %v, want %v", err, domain.ErrInvalidCredentials)
+	}
+
+	if _, err := svc.SignIn(ctx, SignInInput{Email: user.Email, Password: "Password2!"}); err != nil {
+		t.Fatalf("SignIn() with the new password error = %v", err)
+	}
+}
+
+func TestUserService_CreateUser_Referral(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name         string
+		referrerID   uuid.UUID
+		wantReferral int
+	}{
+		{name: "referred by another user", referrerID: uuid.New(), wantReferral: 1},
+		{name: "referred by the new user", referrerID: userID, wantReferral: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, env := newTestUserService(t)
+
+			if _, err := svc.createUser(context.Background(), CreateUserInput{
+				UserId:       userID,
+				Email:        "user@example.com",
+				Password:     "Password1!",
+				ReferralId:   tt.referrerID,
+				ReferralCode: "CODE",
+			}); err != nil {
+				t.Fatalf("createUser() error = %v", err)
+			}
+
+			if _, err := env.users.FindByUserId(context.Background(), userID); err != nil {
+				t.Fatalf("user wasn't created: %v", err)
+			}
+
+			if env.referrals.incrementUsesCalls != tt.wantReferral || env.referrals.createReferralCalls != tt.wantReferral {
+				t.Errorf("code uses incremented %d times and referrals created %d times, want %d",
+					env.referrals.incrementUsesCalls, env.referrals.createReferralCalls, tt.wantReferral)
+			}
+		})
+	}
+}
+
+func TestUserService_RefreshTokens_RedisSessionStore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		prepare     func(env *testEnv, refreshToken string)
+		wantErr     error
+		wantConsume int
+	}{
+		{
+			name:        "cached session skips the lookup in Postgres",
+			prepare:     func(*testEnv, string) {},
+			wantConsume: 0,
+		},
+		{
+			name: "cache miss falls back to Postgres",
+			prepare: func(env *testEnv, refreshToken string) {
+				delete(env.sessionCache.sessions, refreshToken)
+			},
+			wantConsume: 1,
+		},
+		{
+			name: "session revoked in Postgres is rejected",
+			prepare: func(env *testEnv, refreshToken string) {
+				delete(env.sessions.sessions, refreshToken)
+			},
+			wantErr: domain.ErrSessionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, env := newTestUserService(t)
+			svc.authCfg.SessionStore = sessionStoreRedis
+			user := addUser(t, env, hash.NewBcryptHasher(4), "user@example.com", "Password1!")
+
+			tokens, err := svc.SignIn(ctx, SignInInput{Email: user.Email, Password: "Password1!"})
+			if err != nil {
+				t.Fatalf("SignIn() error = %v", err)
+			}
+			tt.prepare(env, tokens.RefreshToken)
+
+			_, err = svc.RefreshTokens(ctx, tokens.RefreshToken, ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshTokens() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if env.sessions.consumeCalls != tt.wantConsume {
+				t.Errorf("Postgres consumes = %d, want %d", env.sessions.consumeCalls, tt.wantConsume)
+			}
+
+			if _, ok := env.sessions.sessions[tokens.RefreshToken]; ok {
+				t.Error("refresh token is still stored in Postgres")
+			}
+
+			if _, err := svc.RefreshTokens(ctx, tokens.RefreshToken, ClientInfo{}); !errors.Is(err, domain.ErrSessionNotFound) {
+				t.Errorf("RefreshTokens() reusing the token error = %v, want %v", err, domain.ErrSessionNotFound)
+			}
+		})
+	}
+}
+
+func TestUserService_ConfirmEmailChange_ForgetsOldEmail(t *testing.T) {
+	svc, env := newTestUserService(t)
+	ctx := context.Background()
+	user := addUser(t, env, hash.NewBcryptHasher(4), "old@example.com", "Password1!")
+
+	value, err := json.Marshal(emailChange{UserID: user.UserId, Email: "new@example.com"})
+	if err != nil {
+		t.Fatalf("encode email change: %v", err)
+	}
+
+	if err := env.emailChange.Create(ctx, "token", string(value), time.Hour); err != nil {
+		t.Fatalf("store email change: %v", err)
+	}
+
+	// A sign in with the old email racing the change caches the old record.
+	env.users.beforeUpdateEmail = func() {
+		if _, err := svc.SignIn(ctx, SignInInput{Email: "old@example.com", Password: "Password1!"}); err != nil {
+			t.Errorf("SignIn() before the update error = %v", err)
+		}
+	}
+
+	if err := svc.ConfirmEmailChange(ctx, "token"); err != nil {
+		t.Fatalf("ConfirmEmailChange() error = %v", err)
+	}
+
+	if env.userCache.has("old@example.com") {
+		t.Fatal("user is still cached under the old email")
+	}
+
+	if _, err := svc.SignIn(ctx, SignInInput{Email: "old@example.com", Password: "Password1!"}); err == nil {
+		t.Fatal("SignIn() with the old email succeeded")
+	}
+
+	if _, err := svc.SignIn(ctx, SignInInput{Email: "new@example.com", Password: "Password1!"}); err != nil {
+		t.Fatalf("SignIn() with the new email error = %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"link-base/internal/config"
+	"link-base/internal/domain"
+	"link-base/pkg/webhook"
+	"log/slog"
+	"time"
+)
+
+// referralWebhookPayload is the body of the referral webhook sent for every referred sign up.
+type referralWebhookPayload struct {
+	Event      string    `json:"event"`
+	ReferrerID string    `json:"referrerId"`
+	UserID     string    `json:"userId"`
+	Code       string    `json:"code"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// newReferralWebhook returns the client of the referral webhook, or nil if it is disabled.
+func newReferralWebhook(cfg config.WebhookConfig) *webhook.Client {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	return webhook.New(cfg.Secret, cfg.Timeout)
+}
+
+// notifyReferral reports the referred sign up to the referral webhook in the background.
+//
+// Deliveries are retried with the configured backoff. Failed deliveries, as well as
+// notifications the webhook queue can't take, are only logged, so they never fail the
+// sign up.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - referral: The created referral.
+func (u *UserService) notifyReferral(ctx context.Context, referral domain.ReferralUser) {
+	if u.webhook == nil || u.webhookQueue == nil {
+		return
+	}
+
+	cfg := u.referralCfg.Webhook
+	payload := referralWebhookPayload{
+		Event:      "referral.created",
+		ReferrerID: referral.Referral.String(),
+		UserID:     referral.UserID.String(),
+		Code:       referral.Code,
+		Timestamp:  time.Now().UTC(),
+	}
+
+	err := u.webhookQueue.Enqueue(func(ctx context.Context) {
+		attempts, err := sendWithRetry(ctx, cfg.Retry, func() error {
+			return u.webhook.Post(ctx, cfg.URL, payload)
+		})
+		if err != nil {
+			u.logger.ErrorContext(ctx, "referral webhook dead-lettered",
+				slog.String("userId", payload.UserID),
+				slog.String("referrerId", payload.ReferrerID),
+				slog.Int("attempts", attempts),
+				slog.String("reason", err.Error()),
+			)
+		}
+	})
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to enqueue referral webhook",
+			slog.String("userId", payload.UserID),
+			slog.String("reason", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"link-base/internal/config"
+	"link-base/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// Cleanup periodically purges expired refresh tokens and referral codes.
+type Cleanup struct {
+	repos  *repository.Repository
+	logger *slog.Logger
+	cfg    config.CleanupConfig
+}
+
+// NewCleanup creates a new instance of Cleanup.
+//
+// Parameters:
+//   - repos: The repositories to purge expired rows from.
+//   - logger: A pointer to a slog logger.
+//   - cfg: A CleanupConfig struct containing the interval and batch size.
+//
+// Returns:
+//   - *Cleanup: A new instance of Cleanup.
+func NewCleanup(repos *repository.Repository, logger *slog.Logger, cfg config.CleanupConfig) *Cleanup {
+	return &Cleanup{
+		repos:  repos,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run purges expired rows every interval until the context is canceled.
+//
+// Note: This method blocks, so it should be started in its own goroutine.
+func (w *Cleanup) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge runs a single cleanup pass and logs how many rows were removed.
+func (w *Cleanup) purge(ctx context.Context) {
+	tokens, err := w.deleteInBatches(ctx, w.repos.RefreshToken.DeleteExpired)
+	if err != nil {
+		w.logger.Error("failed to purge expired refresh tokens", slog.String("reason", err.Error()))
+	}
+
+	codes, err := w.deleteInBatches(ctx, w.repos.Referral.DeleteExpiredCodes)
+	if err != nil {
+		w.logger.Error("failed to purge expired referral codes", slog.String("reason", err.Error()))
+	}
+
+	w.logger.Info("cleanup pass finished",
+		slog.Int64("refresh_tokens", tokens),
+		slog.Int64("referral_codes", codes),
+	)
+}
+
+// deleteInBatches calls deleteBatch until a batch deletes fewer rows than the batch size,
+// so a single pass never holds locks on all expired rows at once.
+func (w *Cleanup) deleteInBatches(ctx context.Context, deleteBatch func(context.Context, int) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := deleteBatch(ctx, w.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < int64(w.cfg.BatchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
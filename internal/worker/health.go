@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"link-base/internal/config"
+	"link-base/pkg/database"
+	"log/slog"
+	"time"
+)
+
+// HealthMonitor periodically checks the dependencies and logs when one of them becomes
+// unhealthy or recovers.
+type HealthMonitor struct {
+	checks []database.Check
+	logger *slog.Logger
+	cfg    config.HealthConfig
+}
+
+// NewHealthMonitor creates a new instance of HealthMonitor.
+//
+// Parameters:
+//   - checks: The health checks of the dependencies.
+//   - logger: A pointer to a slog logger.
+//   - cfg: A HealthConfig struct containing the interval and timeout of the checks.
+//
+// Returns:
+//   - *HealthMonitor: A new instance of HealthMonitor.
+func NewHealthMonitor(checks []database.Check, logger *slog.Logger, cfg config.HealthConfig) *HealthMonitor {
+	return &HealthMonitor{
+		checks: checks,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run checks the dependencies every interval until the context is canceled. Only changes
+// of the health of a dependency are logged, so a long outage is reported once.
+//
+// Note: This method blocks, so it should be started in its own goroutine.
+func (w *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	healthy := make(map[string]bool, len(w.checks))
+	for _, check := range w.checks {
+		healthy[check.Name] = true
+	}
+
+	for {
+		for _, check := range w.checks {
+			err := w.ping(ctx, check)
+			if ctx.Err() != nil {
+				return
+			}
+
+			switch {
+			case err != nil && healthy[check.Name]:
+				w.logger.Error("dependency is unhealthy",
+					slog.String("dependency", check.Name),
+					slog.String("reason", err.Error()),
+				)
+			case err == nil && !healthy[check.Name]:
+				w.logger.Info("dependency recovered", slog.String("dependency", check.Name))
+			}
+
+			healthy[check.Name] = err == nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ping runs a single check bounded by the check timeout.
+func (w *HealthMonitor) ping(ctx context.Context, check database.Check) error {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	return check.Ping(ctx)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// loadRSAPrivateKey reads a PEM encoded RSA private key from the given path.
+//
+// Parameters:
+//   - path: The path to the PEM file.
+//
+// Returns:
+//   - *rsa.PrivateKey: The parsed private key.
+//   - error: An error if the path is empty, the file can't be read or the key can't be parsed.
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return nil, errors.New("empty private key path")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return key, nil
+}
+
+// loadRSAPublicKey reads a PEM encoded RSA public key from the given path.
+//
+// Parameters:
+//   - path: The path to the PEM file.
+//
+// Returns:
+//   - *rsa.PublicKey: The parsed public key.
+//   - error: An error if the path is empty, the file can't be read or the key can't be parsed.
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("empty public key path")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return key, nil
+}
@@ -1,59 +1,239 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/rand"
+	"link-base/internal/config"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTokenExpired is returned by Parse when the token signature is valid
+	// but its exp claim is in the past.
+	ErrTokenExpired = errors.New("token is expired")
+
+	// ErrInvalidToken is returned by Parse when the token is malformed, signed
+	// with an unexpected method or its signature does not match.
+	ErrInvalidToken = errors.New("token is invalid")
+
+	// ErrInvalidIssuer is returned by Parse when the iss claim doesn't match the configured issuer.
+	ErrInvalidIssuer = errors.New("token issuer mismatch")
+
+	// ErrInvalidAudience is returned by Parse when the aud claim doesn't match the configured audience.
+	ErrInvalidAudience = errors.New("token audience mismatch")
 )
 
 type TokenManager interface {
-	NewJWT(userId string, ttl time.Duration) (string, error)
+	NewJWT(userId string, roles []string, ttl time.Duration) (string, error)
 	Parse(accessToken string) (string, error)
+	ParseClaims(accessToken string) (Claims, error)
 	NewRefreshToken() (string, error)
 }
 
+// Claims holds the verified claims of an access token.
+type Claims struct {
+	Subject   string
+	ID        string
+	Roles     []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// tokenClaims is the JWT payload: the registered claims plus the user's roles.
+type tokenClaims struct {
+	jwt.StandardClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+
+	// defaultKeyID is used when no key set is configured and the single SigningKey is used instead.
+	defaultKeyID = "default"
+)
+
 type Manager struct {
-	signingKey string
+	method      jwt.SigningMethod
+	activeKeyID string
+	signingKey  interface{}
+	verifyKeys  map[string]interface{}
+	issuer      string
+	audience    string
+}
+
+// NewManager creates a new instance of Manager that can both mint and verify tokens.
+//
+// For HS256 (the default when no algorithm is configured) tokens are signed with the
+// secret of the active key ID from SigningKeys and verified with the secret matching
+// the token's kid header, so tokens signed with a retired key keep verifying while it
+// stays in the set. If no key set is configured the SigningKey is used as the only key.
+// For RS256 the private key is loaded from PrivateKeyPath and the public key from
+// PublicKeyPath, or derived from the private key if no path is set.
+//
+// Parameters:
+//   - cfg: A JWTConfig struct containing the algorithm and key material.
+//
+// Returns:
+//   - *Manager: A pointer to the newly created Manager instance.
+//   - error: An error if the algorithm is unsupported or the keys can't be loaded.
+func NewManager(cfg config.JWTConfig) (*Manager, error) {
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		return newHMACManager(cfg)
+	case AlgorithmRS256:
+		privateKey, err := loadRSAPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey := &privateKey.PublicKey
+		if cfg.PublicKeyPath != "" {
+			if publicKey, err = loadRSAPublicKey(cfg.PublicKeyPath); err != nil {
+				return nil, err
+			}
+		}
+
+		keyID := activeKeyID(cfg)
+
+		return &Manager{
+			method:      jwt.SigningMethodRS256,
+			activeKeyID: keyID,
+			signingKey:  privateKey,
+			verifyKeys:  map[string]interface{}{keyID: publicKey},
+			issuer:      cfg.Issuer,
+			audience:    cfg.Audience,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", cfg.Algorithm)
+	}
 }
 
-// NewManager creates a new instance of Manager with the provided signingKey.
+// NewVerifier creates a new instance of Manager that can only verify tokens.
+//
+// For RS256 only the public key is loaded from PublicKeyPath, so services that
+// just check access tokens never see the private key. NewJWT on a verify-only
+// manager returns an error.
 //
 // Parameters:
-//   - signingKey: A string used to sign tokens. Must not be empty.
+//   - cfg: A JWTConfig struct containing the algorithm and key material.
 //
 // Returns:
 //   - *Manager: A pointer to the newly created Manager instance.
-//   - error: An error if the signingKey is empty.
-func NewManager(signingKey string) (*Manager, error) {
-	if signingKey == "" {
-		return nil, errors.New("empty signing key")
+//   - error: An error if the algorithm is unsupported or the key can't be loaded.
+func NewVerifier(cfg config.JWTConfig) (*Manager, error) {
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		manager, err := newHMACManager(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		manager.signingKey = nil
+
+		return manager, nil
+	case AlgorithmRS256:
+		publicKey, err := loadRSAPublicKey(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		keyID := activeKeyID(cfg)
+
+		return &Manager{
+			method:      jwt.SigningMethodRS256,
+			activeKeyID: keyID,
+			verifyKeys:  map[string]interface{}{keyID: publicKey},
+			issuer:      cfg.Issuer,
+			audience:    cfg.Audience,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", cfg.Algorithm)
+	}
+}
+
+// newHMACManager creates a Manager that signs and verifies tokens with HS256
+// using the configured key set.
+func newHMACManager(cfg config.JWTConfig) (*Manager, error) {
+	keys := cfg.SigningKeys
+	if len(keys) == 0 {
+		keys = map[string]string{activeKeyID(cfg): cfg.SigningKey}
+	}
+
+	keyID := activeKeyID(cfg)
+
+	verifyKeys := make(map[string]interface{}, len(keys))
+	for kid, secret := range keys {
+		if secret == "" {
+			return nil, fmt.Errorf("empty signing key for key id %q", kid)
+		}
+
+		verifyKeys[kid] = []byte(secret)
+	}
+
+	signingKey, ok := verifyKeys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("active key id %q is not in the signing key set", keyID)
+	}
+
+	return &Manager{
+		method:      jwt.SigningMethodHS256,
+		activeKeyID: keyID,
+		signingKey:  signingKey,
+		verifyKeys:  verifyKeys,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+	}, nil
+}
+
+// activeKeyID returns the configured active key ID or the default one.
+func activeKeyID(cfg config.JWTConfig) string {
+	if cfg.ActiveKeyID == "" {
+		return defaultKeyID
 	}
 
-	return &Manager{signingKey: signingKey}, nil
+	return cfg.ActiveKeyID
 }
 
-// NewJWT creates a new JWT token containing the provided user ID and TTL.
+// NewJWT creates a new JWT token containing the provided user ID, roles and TTL.
 //
-// The subject of the token will be set to the userId, and the expiration time
-// will be set to the current time plus the provided ttl.
+// The subject of the token will be set to the userId, the expiration time
+// will be set to the current time plus the provided ttl and a random token ID
+// (jti) is added so the token can be revoked individually. The kid header is set
+// to the active key ID and the configured issuer and audience are set as registered claims.
 //
 // Parameters:
 //   - userId: The user ID to be included in the token.
+//   - roles: The roles of the user to be included in the token.
 //   - ttl: The TTL for which the token will remain valid.
 //
 // Returns:
 //   - string: The signed JWT token.
-//   - error: An error if the token could not be signed.
-func (m *Manager) NewJWT(userId string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(ttl).Unix(),
-		Subject:   userId,
+//   - error: An error if the token could not be signed or the manager is verify-only.
+func (m *Manager) NewJWT(userId string, roles []string, ttl time.Duration) (string, error) {
+	if m.signingKey == nil {
+		return "", errors.New("token manager can only verify tokens")
+	}
+
+	token := jwt.NewWithClaims(m.method, tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			Subject:   userId,
+			Issuer:    m.issuer,
+			Audience:  m.audience,
+		},
+		Roles: roles,
 	})
+	token.Header["kid"] = m.activeKeyID
 
-	return token.SignedString([]byte(m.signingKey))
+	return token.SignedString(m.signingKey)
 }
 
 // Parse verifies the provided accessToken and returns the user ID contained
@@ -64,26 +244,83 @@ func (m *Manager) NewJWT(userId string, ttl time.Duration) (string, error) {
 //
 // Returns:
 //   - string: The user ID contained in the subject claim of the token.
-//   - error: An error if the token is invalid, or if the subject claim is not
-//     present or could not be parsed as a string.
+//   - error: ErrTokenExpired if the exp claim is in the past, ErrInvalidToken if
+//     the token is malformed, has an invalid signature or no subject claim.
 func (m *Manager) Parse(accessToken string) (string, error) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	claims, err := m.ParseClaims(accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Subject, nil
+}
+
+// ParseClaims verifies the provided accessToken and returns its claims.
+//
+// Parameters:
+//   - accessToken: The JWT token to be verified and parsed.
+//
+// Returns:
+//   - Claims: The subject, token ID, roles, issue and expiration time of the token.
+//   - error: ErrTokenExpired if the exp claim is in the past, ErrInvalidIssuer or
+//     ErrInvalidAudience if the iss or aud claim doesn't match the configured value,
+//     ErrInvalidToken if the token is malformed, has an invalid signature or no subject claim.
+func (m *Manager) ParseClaims(accessToken string) (Claims, error) {
+	var claims tokenClaims
+
+	_, err := jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (i interface{}, err error) {
+		if token.Method.Alg() != m.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return []byte(m.signingKey), nil
+		return m.verifyKey(token)
 	})
 	if err != nil {
-		return "", err
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return Claims{}, ErrTokenExpired
+		}
+
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: subject claim is missing", ErrInvalidToken)
+	}
+
+	if m.issuer != "" && !claims.VerifyIssuer(m.issuer, true) {
+		return Claims{}, fmt.Errorf("%w: got %q", ErrInvalidIssuer, claims.Issuer)
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	if m.audience != "" && !claims.VerifyAudience(m.audience, true) {
+		return Claims{}, fmt.Errorf("%w: got %q", ErrInvalidAudience, claims.Audience)
+	}
+
+	return Claims{
+		Subject:   claims.Subject,
+		ID:        claims.Id,
+		Roles:     claims.Roles,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// verifyKey selects the verification key by the token's kid header.
+//
+// Tokens without a kid header were minted before key rotation was supported
+// and are verified with the active key.
+func (m *Manager) verifyKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = m.activeKeyID
+	}
+
+	key, ok := m.verifyKeys[kid]
 	if !ok {
-		return "", fmt.Errorf("error get user claims from token")
+		return nil, fmt.Errorf("unknown key id: %s", kid)
 	}
 
-	return claims["sub"].(string), nil
+	return key, nil
 }
 
 // NewRefreshToken generates a cryptographically secure random string, which can be used to generate a refresh token.
@@ -92,14 +329,20 @@ func (m *Manager) Parse(accessToken string) (string, error) {
 //   - string: A random string suitable for use as a refresh token.
 //   - error: An error if the random number generator fails.
 func (m *Manager) NewRefreshToken() (string, error) {
-	b := make([]byte, 32)
-
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
+	return NewToken()
+}
 
-	if _, err := r.Read(b); err != nil {
-		return "", err
+// NewToken generates a cryptographically secure random token, e.g. for single-use links
+// sent by email.
+//
+// Returns:
+//   - string: 32 random bytes from crypto/rand, hex encoded.
+//   - error: An error if the random number generator fails.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random token: %w", err)
 	}
 
-	return fmt.Sprintf("%x", b), nil
+	return hex.EncodeToString(b), nil
 }
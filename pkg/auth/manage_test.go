@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"link-base/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, audience string) *Manager {
+	t.Helper()
+
+	manager, err := NewManager(config.JWTConfig{
+		SigningKey: "secret",
+		Issuer:     "link-base",
+		Audience:   audience,
+	})
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+
+	return manager
+}
+
+func TestManager_Parse_Audience(t *testing.T) {
+	minter := newTestManager(t, "service-a")
+
+	token, err := minter.NewJWT("user-id", nil, time.Minute)
+	if err != nil {
+		t.Fatalf("mint token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		audience string
+		wantErr  error
+	}{
+		{name: "same audience", audience: "service-a"},
+		{name: "other audience", audience: "service-b", wantErr: ErrInvalidAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := newTestManager(t, tt.audience).Parse(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && subject != "user-id" {
+				t.Fatalf("Parse() subject = %q, want %q", subject, "user-id")
+			}
+		})
+	}
+}
+
+// writeRSAKeys generates an RSA key pair and writes it to PEM files in a temporary
+// directory, returning the paths of the private and the public key.
+func writeRSAKeys(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+
+	files := map[string]*pem.Block{
+		privatePath: {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		publicPath:  {Type: "PUBLIC KEY", Bytes: publicKey},
+	}
+	for path, block := range files {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	return privatePath, publicPath
+}
+
+func TestManager_RS256(t *testing.T) {
+	privatePath, publicPath := writeRSAKeys(t)
+
+	signer, err := NewManager(config.JWTConfig{Algorithm: AlgorithmRS256, PrivateKeyPath: privatePath})
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+
+	verifier, err := NewVerifier(config.JWTConfig{Algorithm: AlgorithmRS256, PublicKeyPath: publicPath})
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+
+	token, err := signer.NewJWT("user-id", nil, time.Minute)
+	if err != nil {
+		t.Fatalf("mint token: %v", err)
+	}
+
+	subject, err := verifier.Parse(token)
+	if err != nil || subject != "user-id" {
+		t.Fatalf("Parse() = %q, %v; want %q, nil", subject, err, "user-id")
+	}
+
+	if _, err := verifier.NewJWT("user-id", nil, time.Minute); err == nil {
+		t.Error("NewJWT() on a verify-only manager succeeded")
+	}
+
+	hmacToken, err := newTestManager(t, "").NewJWT("user-id", nil, time.Minute)
+	if err != nil {
+		t.Fatalf("mint HS256 token: %v", err)
+	}
+
+	if _, err := verifier.Parse(hmacToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Parse() of an HS256 token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// PingPostgres checks that the PostgreSQL database is reachable.
+//
+// Parameters:
+//   - ctx: The context bounding the check.
+//   - db: A pointer to a sqlx database connection.
+//
+// Returns:
+//   - error: An error if the database can't be reached.
+func PingPostgres(ctx context.Context, db *sqlx.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("postgres is unavailable: %w", err)
+	}
+
+	return nil
+}
+
+// PingRedis checks that Redis is reachable.
+//
+// Parameters:
+//   - ctx: The context bounding the check.
+//   - client: The Redis client.
+//
+// Returns:
+//   - error: An error if Redis can't be reached.
+func PingRedis(ctx context.Context, client redis.UniversalClient) error {
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis is unavailable: %w", err)
+	}
+
+	return nil
+}
+
+// Check is a named health check of a dependency.
+type Check struct {
+	Name string
+	Ping func(ctx context.Context) error
+}
+
+// Checks returns the health checks of the dependencies of the service.
+//
+// Parameters:
+//   - db: The connection to the Postgres primary.
+//   - replica: The connection to the read replica; only checked if it isn't db.
+//   - client: The Redis client.
+//
+// Returns:
+//   - []Check: The checks of Postgres, the replica and Redis.
+func Checks(db, replica *sqlx.DB, client redis.UniversalClient) []Check {
+	checks := []Check{{Name: "postgres", Ping: func(ctx context.Context) error { return PingPostgres(ctx, db) }}}
+
+	if replica != nil && replica != db {
+		checks = append(checks, Check{Name: "replica", Ping: func(ctx context.Context) error {
+			if err := replica.PingContext(ctx); err != nil {
+				return fmt.Errorf("postgres replica is unavailable: %w", err)
+			}
+
+			return nil
+		}})
+	}
+
+	return append(checks, Check{Name: "redis", Ping: func(ctx context.Context) error { return PingRedis(ctx, client) }})
+}
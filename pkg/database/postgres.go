@@ -4,14 +4,19 @@ import (
 	"fmt"
 	"link-base/internal/config"
 
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // NewPostgresClient initializes and returns a connection to a PostgreSQL database using the provided configuration.
 //
+// The connection is retried with backoff as configured, so the database may still be starting up.
+// Queries are traced with OpenTelemetry.
+//
 // Parameters:
-//   - cfg: A PostgresConfig struct containing the database connection details such as host, port, user, database name, password, and SSL mode.
+//   - cfg: A PostgresConfig struct containing the database connection details such as host, port, user, database name, password, SSL mode and retry settings.
 //
 // Returns:
 //   - *sqlx.DB: A pointer to the initialized database connection.
@@ -22,7 +27,49 @@ func NewPostgresClient(cfg config.PostgresConfig) (*sqlx.DB, error) {
 		cfg.Host, cfg.Port, cfg.User, cfg.Database, cfg.Password, cfg.SSLMode,
 	)
 
-	db, err := sqlx.Connect("postgres", dsn)
+	return openPostgres(dsn, cfg.Retry)
+}
+
+// NewPostgresReplica initializes and returns a connection to the read replica of the
+// PostgreSQL database.
+//
+// Parameters:
+//   - cfg: A PostgresConfig struct containing the replica DSN and retry settings.
+//   - primary: The connection to the primary, returned if no replica is configured.
+//
+// Returns:
+//   - *sqlx.DB: A pointer to the replica connection, or the primary without a replica.
+//   - error: An error if the connection to the replica fails.
+func NewPostgresReplica(cfg config.PostgresConfig, primary *sqlx.DB) (*sqlx.DB, error) {
+	if cfg.ReplicaDSN == "" {
+		return primary, nil
+	}
+
+	db, err := openPostgres(cfg.ReplicaDSN, cfg.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+
+	return db, nil
+}
+
+// openPostgres opens a traced connection to the DSN and pings it, retrying with backoff.
+func openPostgres(dsn string, retry config.RetryConfig) (*sqlx.DB, error) {
+	var db *sqlx.DB
+	err := withRetry(retry, func() error {
+		var err error
+		sqlDB, err := otelsql.Open("postgres", dsn,
+			otelsql.WithAttributes(attribute.String("db.system", "postgresql")))
+		if err != nil {
+			return err
+		}
+
+		db = sqlx.NewDb(sqlDB, "postgres")
+		if err = db.Ping(); err != nil {
+			_ = db.Close()
+		}
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -2,33 +2,83 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"link-base/internal/config"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 // NewRedisClient initializes and returns a Redis client using the provided configuration.
 //
+// The mode selects a single node, a Sentinel-managed master or a Cluster client. The initial
+// ping is retried with backoff as configured, so Redis may still be starting up. Commands are
+// traced with OpenTelemetry.
+//
 // Parameters:
-//   - cfg: A RedisConfig struct containing the Redis connection details such as address, password,
-//     database number, dial timeout, read timeout, write timeout, pool size, and minimum idle connections.
+//   - cfg: A RedisConfig struct containing the Redis connection details such as mode, addresses,
+//     master name, credentials, database number, dial timeout, read timeout, write timeout, pool size,
+//     minimum idle connections and retry settings.
 //
 // Returns:
-//   - *redis.Client: A pointer to the initialized Redis client.
-//   - error: An error if the connection to Redis fails.
-func NewRedisClient(cfg config.RedisConfig) (*redis.Client, error) {
-	opts := &redis.Options{
-		Addr:         cfg.Address,
-		DB:           cfg.DatabaseNumber,
-		DialTimeout:  cfg.DialTimeout,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-		PoolSize:     cfg.PoolSize,
-		MinIdleConns: cfg.MinIdleConns,
+//   - redis.UniversalClient: The initialized Redis client.
+//   - error: An error if the mode is unknown or the connection to Redis fails.
+func NewRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	var client redis.UniversalClient
+
+	switch cfg.Mode {
+	case RedisModeStandalone, "":
+		client = redis.NewClient(&redis.Options{
+			Addr:         cfg.Address,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DatabaseNumber,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+		})
+	case RedisModeSentinel:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.SentinelAddrs,
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			DB:            cfg.DatabaseNumber,
+			DialTimeout:   cfg.DialTimeout,
+			ReadTimeout:   cfg.ReadTimeout,
+			WriteTimeout:  cfg.WriteTimeout,
+			PoolSize:      cfg.PoolSize,
+			MinIdleConns:  cfg.MinIdleConns,
+		})
+	case RedisModeCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.ClusterAddrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+		})
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
 	}
 
-	client := redis.NewClient(opts)
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, fmt.Errorf("failed to instrument redis tracing: %w", err)
+	}
 
-	_, err := client.Ping(context.TODO()).Result()
+	err := withRetry(cfg.Retry, func() error {
+		return client.Ping(context.TODO()).Err()
+	})
 	return client, err
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"link-base/internal/config"
+	"time"
+)
+
+// withRetry calls connect until it succeeds or the configured attempts are exhausted.
+//
+// The delay between attempts starts at the base delay and doubles after every failure.
+//
+// Parameters:
+//   - cfg: A RetryConfig struct containing the maximum number of attempts and the base delay.
+//   - connect: The function establishing the connection.
+//
+// Returns:
+//   - error: The error of the last attempt, or nil if an attempt succeeded.
+func withRetry(cfg config.RetryConfig, connect func() error) error {
+	delay := cfg.BaseDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = connect(); err == nil || attempt >= cfg.MaxAttempts {
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NoopSender logs emails instead of sending them, for local development.
+type NoopSender struct {
+	logger *slog.Logger
+}
+
+// NewNoopSender creates a new instance of NoopSender.
+//
+// Parameters:
+//   - logger: A pointer to a slog logger the emails are logged to.
+//
+// Returns:
+//   - *NoopSender: A new instance of NoopSender.
+func NewNoopSender(logger *slog.Logger) *NoopSender {
+	return &NoopSender{logger: logger}
+}
+
+// Send logs the recipient and subject of the email and always succeeds.
+func (s *NoopSender) Send(ctx context.Context, to, subject, _ string) error {
+	s.logger.InfoContext(ctx, "email not sent, noop sender configured",
+		slog.String("to", to),
+		slog.String("subject", subject),
+	)
+
+	return nil
+}
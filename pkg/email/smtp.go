@@ -0,0 +1,107 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"link-base/internal/config"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// ErrInvalidSender is returned when the configured sender is not an email address.
+var ErrInvalidSender = errors.New("invalid sender address")
+
+const (
+	contentTypeText = "text/plain; charset=UTF-8"
+	contentTypeHTML = "text/html; charset=UTF-8"
+)
+
+// SMTPSender sends emails through an SMTP server.
+type SMTPSender struct {
+	cfg config.SMPTConfig
+}
+
+// NewSMTPSender creates a new instance of SMTPSender.
+//
+// Parameters:
+//   - cfg: The SMTP configuration with the server address, credentials and sender address.
+//
+// Returns:
+//   - *SMTPSender: A new instance of SMTPSender.
+func NewSMTPSender(cfg config.SMPTConfig) *SMTPSender {
+	return &SMTPSender{cfg: cfg}
+}
+
+// Send builds a MIME message and sends it from the configured sender address, falling
+// back to the SMTP user if none is set. Bodies starting with an HTML tag are sent as
+// text/html, all others as text/plain.
+//
+// Parameters:
+//   - ctx: The context of the request, unused as net/smtp can't be canceled.
+//   - to: The recipient's email address.
+//   - subject: The subject of the email.
+//   - body: The plain text or HTML body of the email.
+//
+// Returns:
+//   - error: An error if sending the email fails.
+func (s *SMTPSender) Send(_ context.Context, to, subject, body string) error {
+	from := s.cfg.From
+	if from == "" {
+		from = s.cfg.SMPTUser
+	}
+
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidSender, from, err)
+	}
+
+	contentType := contentTypeText
+	if strings.HasPrefix(strings.TrimSpace(body), "<") {
+		contentType = contentTypeHTML
+	}
+
+	message := buildMessage(sender.String(), to, subject, contentType, body)
+
+	smtpAuth := smtp.PlainAuth("", s.cfg.SMPTUser, s.cfg.SMPTPassword, s.cfg.SMPTHost)
+
+	return smtp.SendMail(s.cfg.SMPTHost+":"+s.cfg.SMPTPort, smtpAuth, sender.Address, []string{to}, message)
+}
+
+// IsPermanent reports whether the SMTP server rejected the email with a 5xx reply,
+// e.g. because the recipient doesn't exist, or the sender address is invalid. Such
+// emails should not be retried, while 4xx replies and network errors are transient.
+func IsPermanent(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+
+	return errors.Is(err, ErrInvalidSender)
+}
+
+// buildMessage renders the headers and body of an email as sent over SMTP.
+func buildMessage(from, to, subject, contentType, body string) []byte {
+	var msg bytes.Buffer
+
+	headers := [][2]string{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("UTF-8", subject)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", contentType},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		msg.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return msg.Bytes()
+}
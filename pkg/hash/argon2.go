@@ -0,0 +1,128 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Hasher uses argon2id to hash passwords with a random salt per password.
+type Argon2Hasher struct {
+	time       uint32
+	memory     uint32
+	threads    uint8
+	saltLength uint32
+	keyLength  uint32
+}
+
+// NewArgon2Hasher creates a new Argon2Hasher instance with the provided parameters.
+//
+// Parameters:
+//   - time: The number of passes over the memory.
+//   - memory: The amount of memory used in KiB.
+//   - threads: The number of threads used.
+//   - saltLength: The length of the random salt in bytes.
+//   - keyLength: The length of the derived key in bytes.
+//
+// Returns:
+//   - *Argon2Hasher: A pointer to the newly created Argon2Hasher instance.
+func NewArgon2Hasher(time, memory uint32, threads uint8, saltLength, keyLength uint32) *Argon2Hasher {
+	return &Argon2Hasher{
+		time:       time,
+		memory:     memory,
+		threads:    threads,
+		saltLength: saltLength,
+		keyLength:  keyLength,
+	}
+}
+
+// Hash takes a password and returns it hashed with argon2id, encoded in the
+// standard "$argon2id$v=19$m=...,t=...,p=...$salt$hash" format.
+//
+// Parameters:
+//   - password: The string to be hashed.
+//
+// Returns:
+//   - string: The encoded hash, including the parameters and salt.
+//   - error: An error if the random salt can't be generated.
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, h.keyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Compare reports whether the password matches the encoded argon2id hash.
+//
+// The parameters and salt are read from the encoded hash, so hashes created
+// with different parameters keep verifying.
+//
+// Parameters:
+//   - hash: The encoded hash to compare against.
+//   - password: The plain text password.
+//
+// Returns:
+//   - bool: True if the password matches the hash.
+//   - error: An error if the encoded hash is malformed.
+func (h *Argon2Hasher) Compare(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errors.New("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+
+	if version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// NeedsRehash reports whether the hash was not produced by argon2id or uses different parameters.
+func (h *Argon2Hasher) NeedsRehash(hash string) bool {
+	if schemeOf(hash) != AlgorithmArgon2id {
+		return true
+	}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return true
+	}
+
+	return parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", h.memory, h.time, h.threads)
+}
@@ -0,0 +1,90 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher uses bcrypt to hash passwords with a random salt per password.
+//
+// Passwords are pre-hashed with SHA-256 so inputs longer than bcrypt's 72 byte
+// limit (e.g. a long password combined with the per-user salt) are fully used.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a new BcryptHasher instance with the provided cost.
+//
+// Parameters:
+//   - cost: The bcrypt cost factor.
+//
+// Returns:
+//   - *BcryptHasher: A pointer to the newly created BcryptHasher instance.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+// Hash takes a password and returns its bcrypt hash.
+//
+// Parameters:
+//   - password: The string to be hashed.
+//
+// Returns:
+//   - string: The encoded bcrypt hash.
+//   - error: An error if there was a problem while hashing the password.
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(prehash(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Compare reports whether the password matches the bcrypt hash.
+//
+// Parameters:
+//   - hash: The encoded hash to compare against.
+//   - password: The plain text password.
+//
+// Returns:
+//   - bool: True if the password matches the hash.
+//   - error: An error if the encoded hash is malformed.
+func (h *BcryptHasher) Compare(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), prehash(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// NeedsRehash reports whether the hash was not produced by bcrypt or uses a different cost.
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if schemeOf(hash) != AlgorithmBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return err != nil || cost != h.cost
+}
+
+// isBcrypt reports whether the encoded hash has a bcrypt prefix.
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// prehash reduces the password to a fixed length that fits into bcrypt's input limit.
+func prehash(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+
+	return []byte(base64.StdEncoding.EncodeToString(sum[:]))
+}
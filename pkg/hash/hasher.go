@@ -0,0 +1,140 @@
+package hash
+
+import (
+	"fmt"
+	"link-base/internal/config"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmSHA1     = "sha1"
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+const (
+	maxBcryptCost = 16
+
+	minArgon2Memory     = 8 * 1024
+	maxArgon2Memory     = 4 * 1024 * 1024
+	maxArgon2Time       = 10
+	minArgon2SaltLength = 8
+	minArgon2KeyLength  = 16
+)
+
+// upgradingHasher hashes new passwords with the configured algorithm and verifies
+// existing hashes with the algorithm that produced them, detected by prefix.
+type upgradingHasher struct {
+	algorithm string
+	hashers   map[string]Hasher
+}
+
+// NewHasher creates the Hasher selected by the configuration.
+//
+// The returned Hasher hashes with the configured algorithm but can still verify hashes
+// produced by any supported algorithm, and NeedsRehash reports hashes that use an
+// outdated scheme so they can be upgraded on the next successful sign in.
+//
+// Parameters:
+//   - cfg: A HashConfig struct containing the algorithm and its parameters.
+//
+// Returns:
+//   - Hasher: The configured password hasher.
+//   - error: An error if the algorithm is unsupported or its cost parameters are out of range.
+func NewHasher(cfg config.HashConfig) (Hasher, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmSHA1
+	}
+
+	switch algorithm {
+	case AlgorithmBcrypt:
+		if err := validateBcrypt(cfg.Bcrypt); err != nil {
+			return nil, err
+		}
+	case AlgorithmArgon2id:
+		if err := validateArgon2(cfg.Argon2); err != nil {
+			return nil, err
+		}
+	}
+
+	hashers := map[string]Hasher{
+		AlgorithmSHA1:   NewSHA1Hasher(cfg.Salt),
+		AlgorithmBcrypt: NewBcryptHasher(cfg.Bcrypt.Cost),
+		AlgorithmArgon2id: NewArgon2Hasher(cfg.Argon2.Time, cfg.Argon2.Memory, cfg.Argon2.Threads,
+			cfg.Argon2.SaltLength, cfg.Argon2.KeyLength),
+	}
+
+	if _, ok := hashers[algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", cfg.Algorithm)
+	}
+
+	return &upgradingHasher{
+		algorithm: algorithm,
+		hashers:   hashers,
+	}, nil
+}
+
+// Hash hashes the password with the configured algorithm.
+func (h *upgradingHasher) Hash(password string) (string, error) {
+	return h.hashers[h.algorithm].Hash(password)
+}
+
+// Compare verifies the password with the algorithm that produced the hash.
+func (h *upgradingHasher) Compare(hash, password string) (bool, error) {
+	return h.hashers[schemeOf(hash)].Compare(hash, password)
+}
+
+// NeedsRehash reports whether the hash should be recomputed with the configured algorithm.
+func (h *upgradingHasher) NeedsRehash(hash string) bool {
+	return h.hashers[h.algorithm].NeedsRehash(hash)
+}
+
+// schemeOf detects the algorithm that produced the encoded hash by its prefix.
+// Hashes without a known prefix are legacy SHA1 hex digests.
+func schemeOf(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	case isBcrypt(hash):
+		return AlgorithmBcrypt
+	default:
+		return AlgorithmSHA1
+	}
+}
+
+// validateBcrypt checks that the bcrypt cost is within a sane range.
+func validateBcrypt(cfg config.BcryptConfig) error {
+	if cfg.Cost < bcrypt.MinCost || cfg.Cost > maxBcryptCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, maxBcryptCost, cfg.Cost)
+	}
+
+	return nil
+}
+
+// validateArgon2 checks that the argon2id parameters are within a sane range.
+func validateArgon2(cfg config.Argon2Config) error {
+	if cfg.Time < 1 || cfg.Time > maxArgon2Time {
+		return fmt.Errorf("argon2id time must be between 1 and %d, got %d", maxArgon2Time, cfg.Time)
+	}
+
+	if cfg.Memory < minArgon2Memory || cfg.Memory > maxArgon2Memory {
+		return fmt.Errorf("argon2id memory must be between %d and %d KiB, got %d", minArgon2Memory, maxArgon2Memory, cfg.Memory)
+	}
+
+	if cfg.Threads < 1 {
+		return fmt.Errorf("argon2id threads must be at least 1, got %d", cfg.Threads)
+	}
+
+	if cfg.SaltLength < minArgon2SaltLength {
+		return fmt.Errorf("argon2id salt length must be at least %d, got %d", minArgon2SaltLength, cfg.SaltLength)
+	}
+
+	if cfg.KeyLength < minArgon2KeyLength {
+		return fmt.Errorf("argon2id key length must be at least %d, got %d", minArgon2KeyLength, cfg.KeyLength)
+	}
+
+	return nil
+}
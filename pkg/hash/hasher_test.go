@@ -0,0 +1,75 @@
+package hash
+
+import (
+	"link-base/internal/config"
+	"testing"
+)
+
+func TestHasher_Compare(t *testing.T) {
+	hashers := map[string]Hasher{
+		AlgorithmSHA1:     NewSHA1Hasher("salt"),
+		AlgorithmBcrypt:   NewBcryptHasher(4),
+		AlgorithmArgon2id: NewArgon2Hasher(1, 8*1024, 1, 16, 32),
+	}
+
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("correct-password")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			ok, err := hasher.Compare(hash, "correct-password")
+			if err != nil || !ok {
+				t.Fatalf("Compare(correct) = %v, %v; want true, nil", ok, err)
+			}
+
+			ok, err = hasher.Compare(hash, "wrong-password")
+			if err != nil || ok {
+				t.Fatalf("Compare(wrong) = %v, %v; want false, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestNewHasher_VerifiesEveryScheme(t *testing.T) {
+	hasher, err := NewHasher(config.HashConfig{
+		Algorithm: AlgorithmBcrypt,
+		Salt:      "salt",
+		Bcrypt:    config.BcryptConfig{Cost: 4},
+		Argon2:    config.Argon2Config{Time: 1, Memory: 8 * 1024, Threads: 1, SaltLength: 16, KeyLength: 32},
+	})
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+
+	legacy, err := NewSHA1Hasher("salt").Hash("correct-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	current, err := hasher.Hash("correct-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	for name, hash := range map[string]string{"legacy": legacy, "current": current} {
+		ok, err := hasher.Compare(hash, "correct-password")
+		if err != nil || !ok {
+			t.Errorf("%s: Compare(correct) = %v, %v; want true, nil", name, ok, err)
+		}
+
+		ok, err = hasher.Compare(hash, "wrong-password")
+		if err != nil || ok {
+			t.Errorf("%s: Compare(wrong) = %v, %v; want false, nil", name, ok, err)
+		}
+	}
+
+	if !hasher.NeedsRehash(legacy) {
+		t.Error("NeedsRehash(legacy) = false, want true")
+	}
+
+	if hasher.NeedsRehash(current) {
+		t.Error("NeedsRehash(current) = true, want false")
+	}
+}
@@ -2,12 +2,15 @@ package hash
 
 import (
 	"crypto/sha1"
+	"crypto/subtle"
 	"fmt"
 )
 
-// PasswordHasher provides hashing logic to securely store passwords.
-type PasswordHasher interface {
+// Hasher provides hashing logic to securely store and verify passwords.
+type Hasher interface {
 	Hash(password string) (string, error)
+	Compare(hash, password string) (bool, error)
+	NeedsRehash(hash string) bool
 }
 
 // SHA1Hasher uses SHA1 to hash passwords with provided salt.
@@ -45,3 +48,26 @@ func (h *SHA1Hasher) Hash(password string) (string, error) {
 
 	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt))), nil
 }
+
+// Compare reports whether the password matches the given SHA1 hash.
+//
+// Parameters:
+//   - hash: The stored hash to compare against.
+//   - password: The plain text password.
+//
+// Returns:
+//   - bool: True if the password matches the hash.
+//   - error: An error if there was a problem while hashing the password.
+func (h *SHA1Hasher) Compare(hash, password string) (bool, error) {
+	passwordHash, err := h.Hash(password)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(passwordHash)) == 1, nil
+}
+
+// NeedsRehash reports whether the hash was produced by another algorithm.
+func (h *SHA1Hasher) NeedsRehash(hash string) bool {
+	return schemeOf(hash) != AlgorithmSHA1
+}
@@ -0,0 +1,293 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The runner shares goose's version table, so databases migrated with the goose CLI
+// keep their history.
+const (
+	versionTable = "goose_db_version"
+
+	// lockID is the Postgres advisory lock serializing concurrent runners.
+	lockID = 8236741062
+)
+
+var ErrNoMigration = errors.New("no applied migration to roll back")
+
+// Migration is a versioned schema change parsed from a goose-annotated SQL file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load parses the *.sql files at the root of fsys, ordered by version.
+//
+// The version is the numeric prefix of the file name before the first underscore. The
+// statements following "-- +goose Up" and "-- +goose Down" form the up and down sections.
+//
+// Parameters:
+//   - fsys: The file system holding the migration files.
+//
+// Returns:
+//   - []Migration: The migrations in ascending version order.
+//   - error: An error if a file can't be read, is misnamed or has no up section.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: file name must start with a positive version", name)
+		}
+
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+
+		m := parse(string(raw))
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %s: missing -- +goose Up section", name)
+		}
+		m.Version = version
+		m.Name = strings.TrimSuffix(path.Base(name), ".sql")
+
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s share a version",
+				migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// parse splits a goose-annotated SQL file into its up and down sections. Other goose
+// annotations are ignored, since every section is executed as one batch.
+func parse(content string) Migration {
+	var (
+		m       Migration
+		section *string
+		b       strings.Builder
+	)
+
+	flush := func() {
+		if section != nil {
+			*section = b.String()
+		}
+		b.Reset()
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			flush()
+			section = &m.Up
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			flush()
+			section = &m.Down
+		case strings.HasPrefix(trimmed, "-- +goose"):
+		default:
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	flush()
+
+	return m
+}
+
+// Up applies every migration of fsys that isn't applied yet, in version order.
+//
+// Each migration runs in its own transaction together with its version record, so a
+// failed migration leaves the schema at the last applied version. Running Up on a
+// current database is a no-op.
+//
+// Parameters:
+//   - ctx: The context for controlling the migration lifecycle.
+//   - db: The database to be migrated.
+//   - fsys: The file system holding the migration files.
+//
+// Returns:
+//   - []Migration: The migrations applied by this call.
+//   - error: An error if the migrations can't be loaded or a migration fails.
+func Up(ctx context.Context, db *sqlx.DB, fsys fs.FS) ([]Migration, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+
+			err := run(ctx, conn, m.Up,
+				`INSERT INTO `+versionTable+` (version_id, is_applied) VALUES ($1, TRUE)`, m.Version)
+			if err != nil {
+				return fmt.Errorf("apply migration %s: %w", m.Name, err)
+			}
+
+			done = append(done, m)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the most recently applied migration.
+//
+// Parameters:
+//   - ctx: The context for controlling the migration lifecycle.
+//   - db: The database to be migrated.
+//   - fsys: The file system holding the migration files.
+//
+// Returns:
+//   - Migration: The rolled back migration.
+//   - error: ErrNoMigration if no migration of fsys is applied, or an error if the
+//     migrations can't be loaded or the rollback fails.
+func Down(ctx context.Context, db *sqlx.DB, fsys fs.FS) (Migration, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	var done Migration
+	err = withLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if !applied[m.Version] {
+				continue
+			}
+
+			err := run(ctx, conn, m.Down,
+				`DELETE FROM `+versionTable+` WHERE version_id = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("roll back migration %s: %w", m.Name, err)
+			}
+
+			done = m
+			return nil
+		}
+
+		return ErrNoMigration
+	})
+
+	return done, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock, so
+// instances migrating at the same time apply every migration only once.
+func withLock(ctx context.Context, db *sqlx.DB, fn func(conn *sqlx.Conn) error) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	const createQuery = `
+		CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+			id SERIAL PRIMARY KEY,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP DEFAULT NOW()
+		)
+	`
+
+	if _, err := conn.ExecContext(ctx, createQuery); err != nil {
+		return fmt.Errorf("create version table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the versions whose latest record marks them as applied.
+func appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int64]bool, error) {
+	var rows []struct {
+		Version int64 `db:"version_id"`
+		Applied bool  `db:"is_applied"`
+	}
+
+	const findQuery = `
+		SELECT DISTINCT ON (version_id) version_id, is_applied
+		FROM ` + versionTable + `
+		ORDER BY version_id, id DESC
+	`
+
+	if err := conn.SelectContext(ctx, &rows, findQuery); err != nil {
+		return nil, fmt.Errorf("find applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.Applied
+	}
+
+	return applied, nil
+}
+
+// run executes the statements of a migration section and records the version change in
+// one transaction.
+func run(ctx context.Context, conn *sqlx.Conn, statements, record string, version int64) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(statements) != "" {
+		if _, err := tx.ExecContext(ctx, statements); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
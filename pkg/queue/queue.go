@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrQueueFull   = errors.New("queue is full")
+	ErrQueueClosed = errors.New("queue is closed")
+)
+
+// Job is a unit of work executed by a queue worker.
+type Job func(ctx context.Context)
+
+// Queue is an in-process job queue backed by a buffered channel and processed
+// by a fixed pool of workers.
+type Queue struct {
+	jobs    chan Job
+	workers int
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a new instance of Queue.
+//
+// Parameters:
+//   - size: The number of jobs that can wait in the queue.
+//   - workers: The number of jobs processed concurrently.
+//
+// Returns:
+//   - *Queue: A new instance of Queue.
+func New(size, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Queue{
+		jobs:    make(chan Job, size),
+		workers: workers,
+	}
+}
+
+// Enqueue adds a job to the queue without blocking.
+//
+// Parameters:
+//   - job: The job to execute in the background.
+//
+// Returns:
+//   - error: ErrQueueFull if the queue has no free slot, or ErrQueueClosed if the
+//     queue is shutting down.
+func (q *Queue) Enqueue(job Job) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run starts the workers and processes jobs until the context is canceled.
+//
+// After cancellation the queue stops accepting jobs, and the jobs already queued
+// are still processed before Run returns. Jobs are executed with a context that
+// carries the values of ctx but is not canceled with it.
+//
+// Note: This method blocks, so it should be started in its own goroutine.
+func (q *Queue) Run(ctx context.Context) {
+	jobCtx := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for range q.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range q.jobs {
+				job(jobCtx)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+
+	q.mu.Lock()
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	wg.Wait()
+}
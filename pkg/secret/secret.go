@@ -0,0 +1,94 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Cipher encrypts short secrets at rest with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a new instance of Cipher.
+//
+// Parameters:
+//   - key: The hex-encoded 32-byte encryption key.
+//
+// Returns:
+//   - *Cipher: A new instance of Cipher.
+//   - error: An error if the key is not 32 hex-encoded bytes.
+func NewCipher(key string) (*Cipher, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	if len(raw) != 32 {
+		return nil, errors.New("invalid encryption key: must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts the plaintext with a random nonce.
+//
+// Parameters:
+//   - plaintext: The secret to encrypt.
+//
+// Returns:
+//   - string: The base64-encoded nonce and ciphertext.
+//   - error: An error if the random source fails.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+//
+// Parameters:
+//   - ciphertext: The base64-encoded nonce and ciphertext.
+//
+// Returns:
+//   - string: The plaintext.
+//   - error: An error if the value is malformed or was not encrypted with this key.
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("invalid ciphertext: too short")
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader is the header carrying the HMAC-SHA256 signature of the request body,
+// formatted as "sha256=<hex digest>".
+const SignatureHeader = "X-Webhook-Signature"
+
+// Client posts signed JSON payloads to webhook receivers.
+type Client struct {
+	http   *http.Client
+	secret []byte
+}
+
+// New creates a new instance of Client.
+//
+// Parameters:
+//   - secret: The secret shared with the receivers to sign the payloads.
+//   - timeout: The maximum duration of a single delivery.
+//
+// Returns:
+//   - *Client: A new instance of Client.
+func New(secret string, timeout time.Duration) *Client {
+	return &Client{
+		http:   &http.Client{Timeout: timeout},
+		secret: []byte(secret),
+	}
+}
+
+// Post sends the payload as JSON to the URL, signed in the SignatureHeader.
+//
+// Parameters:
+//   - ctx: The context for controlling the request lifecycle.
+//   - url: The URL of the receiver.
+//   - payload: The value to be encoded as the request body.
+//
+// Returns:
+//   - error: An error if the payload can't be encoded, the request fails or the
+//     receiver doesn't respond with a 2xx status.
+func (c *Client) Post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(c.secret, body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("deliver webhook: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of the body as sent in the SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the body, in constant time.
+//
+// Receivers call it with the raw request body and the value of the SignatureHeader.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
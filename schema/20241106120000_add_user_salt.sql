@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN salt VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS salt;
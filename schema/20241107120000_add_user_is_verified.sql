@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Accounts created before verification was required stay usable.
+UPDATE users SET is_verified = TRUE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS is_verified;
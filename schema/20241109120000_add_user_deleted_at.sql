@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+-- Emails of deactivated users can be reused, so uniqueness only applies to active users.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS users_email_active_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
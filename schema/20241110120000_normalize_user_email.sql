@@ -0,0 +1,41 @@
+-- +goose Up
+-- The case-sensitive index would reject lowercasing emails that differ only in case.
+DROP INDEX IF EXISTS users_email_active_key;
+
+-- Of the active users sharing an email up to case, the one who signed in most recently keeps
+-- it. The others are deactivated like a deleted account and reported, so lowercasing can't
+-- produce duplicates.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    duplicate RECORD;
+BEGIN
+    FOR duplicate IN
+        UPDATE users SET deleted_at = NOW()
+        WHERE user_id IN (
+            SELECT user_id FROM (
+                SELECT user_id, row_number() OVER (
+                    PARTITION BY lower(trim(email))
+                    ORDER BY last_login_at DESC NULLS LAST, is_verified DESC, user_id
+                ) AS rank
+                FROM users
+                WHERE deleted_at IS NULL
+            ) ranked
+            WHERE rank > 1
+        )
+        RETURNING user_id, email
+    LOOP
+        DELETE FROM refresh_token WHERE user_id = duplicate.user_id;
+        RAISE WARNING 'deactivated user % whose email % differs from another only in case',
+            duplicate.user_id, duplicate.email;
+    END LOOP;
+END $$;
+-- +goose StatementEnd
+
+UPDATE users SET email = lower(trim(email));
+
+CREATE UNIQUE INDEX users_email_active_key ON users (lower(email)) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS users_email_active_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
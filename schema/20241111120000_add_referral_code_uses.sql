@@ -0,0 +1,8 @@
+-- +goose Up
+-- max_uses = 0 means the code can be used an unlimited number of times.
+ALTER TABLE referral_code ADD COLUMN max_uses INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE referral_code ADD COLUMN uses INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE referral_code DROP COLUMN IF EXISTS uses;
+ALTER TABLE referral_code DROP COLUMN IF EXISTS max_uses;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE referral ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_referral_referred_by_created_at ON referral (referred_by_user_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_referral_referred_by_created_at;
+ALTER TABLE referral DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- code is NULL for users who signed up before codes were recorded.
+ALTER TABLE referral ADD COLUMN code VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE referral DROP COLUMN IF EXISTS code;
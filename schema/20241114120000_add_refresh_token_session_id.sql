@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE refresh_token ADD COLUMN session_id uuid NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX idx_refresh_token_session_id ON refresh_token (session_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_refresh_token_session_id;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS session_id;
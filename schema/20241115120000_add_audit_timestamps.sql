@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+ALTER TABLE refresh_token ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE refresh_token ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+ALTER TABLE referral_code ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE referral_code ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- referral already has created_at.
+ALTER TABLE referral ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE referral DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE referral_code DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE referral_code DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE refresh_token
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN ip VARCHAR(45) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent;
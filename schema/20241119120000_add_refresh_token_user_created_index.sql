@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_refresh_token_user_id_created_at ON refresh_token (user_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_refresh_token_user_id_created_at;
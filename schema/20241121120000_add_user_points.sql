@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN points BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS points;
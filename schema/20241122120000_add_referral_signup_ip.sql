@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE referral ADD COLUMN signup_ip VARCHAR(45);
+ALTER TABLE referral ADD COLUMN flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE referral DROP COLUMN IF EXISTS flagged;
+ALTER TABLE referral DROP COLUMN IF EXISTS signup_ip;
@@ -0,0 +1,9 @@
+// Package schema embeds the goose-annotated SQL migrations of the database.
+package schema
+
+import "embed"
+
+// Migrations holds the migration files, named "<version>_<description>.sql".
+//
+//go:embed *.sql
+var Migrations embed.FS